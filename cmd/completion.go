@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jclem/konk/konk"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// completionFunc completes a flag value or argument.
+type completionFunc = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completeNames returns the names that start with toComplete. A leading "!",
+// which excludes names in -n patterns, is kept.
+func completeNames(names []string, toComplete string) []string {
+	prefix := ""
+	if strings.HasPrefix(toComplete, "!") {
+		prefix, toComplete = "!", toComplete[1:]
+	}
+
+	matches := []string{}
+
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) && !slices.Contains(matches, prefix+name) {
+			matches = append(matches, prefix+name)
+		}
+	}
+
+	return matches
+}
+
+// completeWith turns a function listing every candidate into a completion
+// function. Candidates are looked up relative to --working-directory, as they
+// would be when running.
+func completeWith(list func(args []string) ([]string, error)) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if workingDirectory != "" {
+			if err := os.Chdir(workingDirectory); err != nil {
+				cobra.CompDebugln(fmt.Sprintf("changing working directory: %s", err), true)
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+		}
+
+		resetSliceFlags(cmd)
+
+		// Arguments after -- are forwarded to scripts, not commands.
+		if n := cmd.ArgsLenAtDash(); n >= 0 {
+			args = args[:n]
+		}
+
+		// While a command line is half-typed, it's often invalid, e.g. with
+		// more commands than --cwd flags so far, so there's nothing to offer.
+		names, err := list(args)
+		if err != nil {
+			cobra.CompDebugln(err.Error(), true)
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		return completeNames(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// resetSliceFlags sets cmd's slice flags, such as -n, once more from the
+// command line being completed. Cobra parses flags twice before completing, so
+// they would otherwise hold each value twice over.
+func resetSliceFlags(cmd *cobra.Command) {
+	// os.Args holds konk, cobra's __complete command, the command line, and
+	// the word being completed.
+	if len(os.Args) < 3 {
+		return
+	}
+
+	_, raw, err := cmd.Root().Find(os.Args[2 : len(os.Args)-1])
+	if err != nil {
+		cobra.CompDebugln(fmt.Sprintf("finding arguments: %s", err), true)
+		return
+	}
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			_ = v.Replace([]string{})
+		}
+	})
+
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.Flags())
+
+	// Parsing fails at a flag whose value is the word being completed, but only
+	// once every flag before it has been set.
+	_ = flags.ParseAll(raw, func(flag *pflag.Flag, value string) error {
+		if _, ok := flag.Value.(pflag.SliceValue); !ok {
+			return nil
+		}
+
+		_ = flag.Value.Set(value)
+
+		return nil
+	})
+}
+
+// scriptNames lists the package.json scripts -n can run: those of the current
+// directory, or with --workspaces, those of every workspace package.
+func scriptNames([]string) ([]string, error) {
+	if !workspaces {
+		pkgJSON, err := konk.ReadPackageJSON(".")
+		if err != nil {
+			return nil, err
+		}
+
+		return pkgJSON.Scripts.Names(), nil
+	}
+
+	found, err := konk.FindWorkspaces(".")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, ws := range found {
+		for _, name := range ws.Package.Scripts.Names() {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// taskNames returns a function listing the tasks source defines.
+func taskNames(source konk.TaskSource) func([]string) ([]string, error) {
+	return func([]string) ([]string, error) {
+		tasks, err := source.Tasks(".")
+		if err != nil {
+			return nil, err
+		}
+
+		return tasks.Names(), nil
+	}
+}
+
+// labelNames suggests labels for the commands given so far that don't have
+// one yet: each command as given, as -L would label it.
+func labelNames(args []string) ([]string, error) {
+	list, err := collectCommands(args, nil, flagSelection())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) >= len(list.provided) {
+		return nil, nil
+	}
+
+	return list.provided[len(names):], nil
+}
+
+// procNames lists the Procfile's process types, skipping those already given.
+func procNames(args []string) ([]string, error) {
+	entries, err := konk.ReadProcfile(procfile)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		if !slices.Contains(args, entry.Name) {
+			names = append(names, entry.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// procDirNames lists the Procfile's process types as the start of a --cwd
+// <type>:DIR value.
+func procDirNames([]string) ([]string, error) {
+	names, err := procNames(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		names[i] = name + ":"
+	}
+
+	return names, nil
+}
+
+// completeProcDir completes the process type of a --cwd <type>:DIR value for
+// "konk proc", leaving the directory to the shell.
+func completeProcDir(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.Contains(toComplete, ":") {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	names, directive := completeWith(procDirNames)(cmd, args, toComplete)
+
+	return names, directive | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeDirs completes a flag value with a directory.
+func completeDirs(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// packageManagerNames lists the values --package-manager accepts.
+func packageManagerNames([]string) ([]string, error) {
+	names := make([]string, len(konk.PackageManagers))
+	for i, pm := range konk.PackageManagers {
+		names[i] = string(pm)
+	}
+
+	return names, nil
+}
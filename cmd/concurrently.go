@@ -10,9 +10,11 @@ import (
 )
 
 var aggregateOutput bool
+var outputOrder string
+var groupHeader bool
 
 var cCommand = cobra.Command{
-	Use:     "concurrently <command...>",
+	Use:     "concurrently <command...> [-- <script args...>]",
 	Aliases: []string{"c"},
 	Short:   "Run commands concurrently (alias: c)",
 	Example: `# Run two commands concurrently
@@ -23,14 +25,59 @@ konk run concurrently "script/api-server" "script/frontend-server"
 
 konk run concurrently -n lint -n test
 
+# Run two commands concurrently with variables from .env.test, and set PORT for
+# only the first
+
+konk run concurrently -e .env.test -l api -l web --env api:PORT=4000 \
+  "script/api-server" "script/frontend-server"
+
+# Run a command in each of two directories concurrently
+
+konk run concurrently --cwd api "go run ." --cwd web "npm run dev"
+
+# Run the build script in the web directory, and a command in the current one
+
+konk run concurrently --cwd build:web -n build "go run ."
+
 # Run a set of npm commands concurrently, but aggregate their output
 
 konk run concurrently -g -n lint -n test
 
+# Run a set of npm commands concurrently, but print their aggregated output in
+# the order they were given
+
+konk run concurrently -g --order=declared -n lint -n test
+
 # Run all npm commands prefixed with "check:" concurrently using Bun, ignore
 # errors, aggregate output, and use the script name as the label
 
-konk run concurrently -bgcL -n "check:*"`,
+konk run concurrently -bgcL -n "check:*"
+
+# Run the Makefile's build target and package.json's build script concurrently
+
+konk run concurrently --make build -n build
+
+# Run the lint and test scripts with /bin/sh, without starting npm for each
+
+konk run concurrently --direct -n lint -n test
+
+# Run two commands with bash, failing if any part of a pipeline fails
+
+konk run concurrently --shell bash --shell-opts "set -euo pipefail" \
+  "make | tee build.log" "npm test | tee test.log"
+
+# Run two commands without a shell, expanding $PORT from .env and the glob
+
+konk run concurrently -S --expand -e .env "go test ./..." 'server --port $PORT config/*.toml'
+
+# Build every workspace package, each after the packages it depends on
+
+konk run concurrently --workspaces --topological -n build
+
+# Run every lint script at any depth except the fixers, e.g. "lint:js" and
+# "lint:css:strict" but not "lint:js:fix"
+
+konk run concurrently -n "lint:**" -n "!lint:**:fix"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
@@ -44,26 +91,65 @@ konk run concurrently -bgcL -n "check:*"`,
 			}
 		}
 
-		cmdStrings, cmdParts, err := collectCommands(args)
+		order := konk.OutputOrder(outputOrder)
+		if order != konk.OrderCompletion && order != konk.OrderDeclared {
+			return fmt.Errorf("invalid output order %q (must be %q or %q)",
+				outputOrder, konk.OrderCompletion, konk.OrderDeclared)
+		}
+
+		if !aggregateOutput && (cmd.Flags().Changed("order") || groupHeader) {
+			return errors.New("--order and --group-header require --aggregate-output")
+		}
+
+		args, forwarded := splitForwardedArgs(cmd, args)
+
+		list, err := collectCommands(args, forwarded, flagSelection())
 		if err != nil {
 			return err
 		}
 
-		if len(names) > 0 && len(names) != len(cmdParts) {
+		if len(names) > 0 && len(names) != len(list.runnable) {
 			return errors.New("number of names must match number of commands")
 		}
 
-		labels := collectLabels(cmdStrings)
+		labels := collectLabels(list.provided)
+		targets := labelTargets(labels)
+
+		env, commandEnv, err := resolveEnv(targets, false)
+		if err != nil {
+			return err
+		}
+
+		commandShells, err := resolveShells(targets)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			plan := newPlanStage("concurrently", list, labels, commandEnv, commandShells)
+			return printPlan(cmd.OutOrStdout(), []planStage{plan}, false)
+		}
 
 		commands, err := konk.RunConcurrently(ctx, konk.RunConcurrentlyConfig{
-			Commands:        cmdParts,
+			Commands:        list.runnable,
 			Labels:          labels,
-			Env:             make([]string, 0),
-			OmitEnv:         false,
+			Names:           nil,
+			EnvVars:         env,
+			CommandEnv:      commandEnv,
+			Dirs:            list.dirs,
+			OmitEnv:         omitEnv,
 			AggregateOutput: aggregateOutput,
+			OutputOrder:     order,
+			GroupHeader:     groupHeader,
 			ContinueOnError: continueOnError,
 			NoColor:         noColor,
 			NoShell:         noShell,
+			Expand:          expand,
+			Shells:          commandShells,
+			ShellOpts:       shellOpts,
+			ControlSocket:   controlSocketPath(),
+			Interactive:     interactive,
+			DependsOn:       list.dependsOn,
 		})
 
 		debugCommands(ctx, commands)
@@ -78,5 +164,11 @@ konk run concurrently -bgcL -n "check:*"`,
 
 func init() {
 	cCommand.Flags().BoolVarP(&aggregateOutput, "aggregate-output", "g", false, "aggregate command output")
+	cCommand.Flags().StringVar(&outputOrder, "order", string(konk.OrderCompletion),
+		"order of aggregated output: \"completion\" or \"declared\"")
+	cCommand.Flags().BoolVar(&groupHeader, "group-header", false,
+		"wrap aggregated output in a header and footer with the label and duration")
+	cCommand.Flags().BoolVar(&control, "control", false, "serve a control API on "+konk.DefaultControlSocket)
+	cCommand.Flags().BoolVarP(&interactive, "interactive", "i", false, "accept commands such as \"rs <name>\" on stdin")
 	runCommand.AddCommand(&cCommand)
 }
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jclem/konk/konk"
+	"github.com/spf13/cobra"
+)
+
+var control bool
+var controlSocket string
+var interactive bool
+var followLogs bool
+
+var ctlCommand = cobra.Command{
+	Use:   "ctl <subcommand>",
+	Short: "Control a running konk session",
+	Long: `Control a running konk session
+
+The session must have been started with --control, which serves a control API
+on a Unix socket in its working directory.`,
+	Example: `# Show the status of every command in the session
+
+konk ctl status
+
+# Restart the "web" process
+
+konk ctl restart web
+
+# Follow the output of the "worker" process
+
+konk ctl logs worker --follow`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		_ = cmd.Help()
+		os.Exit(1)
+		return nil
+	},
+}
+
+var ctlStatusCommand = cobra.Command{
+	Use:   "status",
+	Short: "Show the status of each command",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return sendControl(cmd, konk.ControlRequest{Action: konk.ControlStatus, Names: nil, Follow: false})
+	},
+}
+
+var ctlRestartCommand = cobra.Command{
+	Use:   "restart <name...>",
+	Short: "Restart commands, or start them again if they have exited",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendControl(cmd, konk.ControlRequest{Action: konk.ControlRestart, Names: args, Follow: false})
+	},
+}
+
+var ctlStopCommand = cobra.Command{
+	Use:   "stop <name...>",
+	Short: "Stop commands without ending the session",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendControl(cmd, konk.ControlRequest{Action: konk.ControlStop, Names: args, Follow: false})
+	},
+}
+
+var ctlLogsCommand = cobra.Command{
+	Use:   "logs <name>",
+	Short: "Print a command's recent output",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendControl(cmd, konk.ControlRequest{Action: konk.ControlLogs, Names: args, Follow: followLogs})
+	},
+}
+
+func sendControl(cmd *cobra.Command, req konk.ControlRequest) error {
+	if workingDirectory != "" {
+		if err := os.Chdir(workingDirectory); err != nil {
+			return fmt.Errorf("changing working directory: %w", err)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+
+	err := konk.SendControl(cmd.Context(), controlSocket, req, func(resp konk.ControlResponse) error {
+		if req.Action == konk.ControlLogs {
+			fmt.Fprintln(out, resp.Line)
+			return nil
+		}
+
+		return konk.WriteStatus(out, resp.Commands)
+	})
+
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no control socket at %s; start konk with --control", controlSocket)
+	}
+
+	if err != nil {
+		return fmt.Errorf("controlling session: %w", err)
+	}
+
+	return nil
+}
+
+func init() {
+	ctlCommand.PersistentFlags().StringVarP(&workingDirectory,
+		"working-directory", "w", "", "set the working directory of the session")
+	ctlCommand.PersistentFlags().StringVarP(&controlSocket,
+		"socket", "s", konk.DefaultControlSocket, "path to the session's control socket")
+	ctlLogsCommand.Flags().BoolVarP(&followLogs, "follow", "f", false, "stream new output as it is written")
+
+	ctlCommand.AddCommand(&ctlStatusCommand)
+	ctlCommand.AddCommand(&ctlRestartCommand)
+	ctlCommand.AddCommand(&ctlStopCommand)
+	ctlCommand.AddCommand(&ctlLogsCommand)
+	rootCmd.AddCommand(&ctlCommand)
+}
+
+// controlSocketPath returns the control socket a session should serve, or an
+// empty string if --control was not given.
+func controlSocketPath() string {
+	if !control {
+		return ""
+	}
+
+	return konk.DefaultControlSocket
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var dryRun bool
+
+// planStage is a group of commands as --dry-run describes them.
+type planStage struct {
+	// how describes how the stage's commands run, e.g. "serially".
+	how      string
+	commands []planCommand
+}
+
+// planCommand is a command as --dry-run describes it.
+type planCommand struct {
+	label   string
+	command string
+	dir     string
+	// env holds the variables set for only this command.
+	env []string
+	// shell is the shell the command runs in, if not the default.
+	shell string
+	// after holds the labels of the commands this one waits for.
+	after []string
+}
+
+// newPlanStage describes the commands in list, labeled by labels and with the
+// variables in commandEnv and the shells in shells.
+func newPlanStage(how string, list *commandList, labels []string, commandEnv [][]string, shells []string) planStage {
+	commands := make([]planCommand, len(list.runnable))
+
+	for i, runnable := range list.runnable {
+		after := make([]string, len(list.dependsOn[i]))
+		for j, dep := range list.dependsOn[i] {
+			after[j] = planLabel(labels, dep)
+		}
+
+		commands[i] = planCommand{
+			label:   strings.TrimSpace(labels[i]),
+			command: runnable,
+			dir:     list.dirs[i],
+			env:     commandEnv[i],
+			shell:   planShell(shells, i),
+			after:   after,
+		}
+	}
+
+	return planStage{how: how, commands: commands}
+}
+
+// planLabel returns the label of command i, or its index if it has none.
+func planLabel(labels []string, i int) string {
+	if label := strings.TrimSpace(labels[i]); label != "" {
+		return label
+	}
+
+	return strconv.Itoa(i)
+}
+
+// planShell returns the shell command i runs in, if shells sets one.
+func planShell(shells []string, i int) string {
+	if len(shells) == 0 {
+		return ""
+	}
+
+	return shells[i]
+}
+
+// printPlan prints what would run, for --dry-run: the variables loaded from
+// env files, with their values masked, those set with --env, and each stage's
+// commands in the order they would start. See loadEnv for layered.
+func printPlan(w io.Writer, stages []planStage, layered bool) error {
+	vars, err := loadEnv(layered)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if len(vars) > 0 {
+		fmt.Fprintln(tw, "Env files:")
+
+		for _, v := range vars {
+			fmt.Fprintf(tw, "  %s=%s\t# %s:%d\n", v.Key, maskEnvValue(v.Value), v.File, v.Line)
+		}
+	}
+
+	var global []string
+
+	for _, flag := range inlineEnv {
+		if pair, target, err := parseInlineEnv(flag); err == nil && target == "" {
+			global = append(global, pair)
+		}
+	}
+
+	if len(global) > 0 {
+		fmt.Fprintln(tw, "Env:")
+
+		for _, pair := range global {
+			fmt.Fprintf(tw, "  %s\n", pair)
+		}
+	}
+
+	if shellOpts != "" {
+		fmt.Fprintln(tw, "Shell options:")
+		fmt.Fprintf(tw, "  %s\n", shellOpts)
+	}
+
+	for i, stage := range stages {
+		if len(stages) > 1 {
+			fmt.Fprintf(tw, "Stage %d, run %s:\n", i+1, stage.how)
+		} else {
+			fmt.Fprintf(tw, "Run %s:\n", stage.how)
+		}
+
+		if len(stage.commands) == 0 {
+			fmt.Fprintln(tw, "  (no commands)")
+		}
+
+		for _, c := range stage.commands {
+			if c.label != "" {
+				fmt.Fprintf(tw, "  [%s] %s\n", c.label, c.command)
+			} else {
+				fmt.Fprintf(tw, "  %s\n", c.command)
+			}
+
+			if c.shell != "" {
+				fmt.Fprintf(tw, "      using %s\n", c.shell)
+			}
+
+			if c.dir != "" {
+				fmt.Fprintf(tw, "      in %s\n", c.dir)
+			}
+
+			if len(c.after) > 0 {
+				fmt.Fprintf(tw, "      after %s\n", strings.Join(c.after, ", "))
+			}
+
+			for _, pair := range c.env {
+				fmt.Fprintf(tw, "      with %s\n", pair)
+			}
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing plan: %w", err)
+	}
+
+	return nil
+}
+
+// maskEnvValue hides a value loaded from an env file, which may be a secret.
+func maskEnvValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	return "***"
+}
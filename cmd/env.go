@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jclem/konk/konk"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var envFiles []string
+var envMode string
+var noEnvFile bool
+var omitEnv bool
+var inlineEnv []string
+
+var envCommand = cobra.Command{
+	Use:   "env",
+	Short: "Print variables loaded from env files and where each came from",
+	Example: `# Print the variables from .env and .env.local
+
+konk env
+
+# Print the variables from .env, .env.local, .env.test, and .env.test.local
+
+konk env --mode test`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if workingDirectory != "" {
+			if err := os.Chdir(workingDirectory); err != nil {
+				return fmt.Errorf("changing working directory: %w", err)
+			}
+		}
+
+		vars, err := loadEnv(true)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		for _, v := range vars {
+			fmt.Fprintf(w, "%s=%s\t# %s:%d\n", v.Key, quoteEnvValue(v.Value), v.File, v.Line)
+		}
+
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("writing env: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// addEnvFlags adds the flags that select env files to load. If layered is set,
+// .env and .env.local are loaded by default, and otherwise only with --mode.
+func addEnvFlags(flags *pflag.FlagSet, layered bool) {
+	if layered {
+		flags.StringArrayVarP(&envFiles, "env-file", "e", []string{},
+			"Path to an env file; may be repeated (default .env and .env.local, if present)")
+		flags.StringVar(&envMode, "mode", "", "Also load .env.<mode> and .env.<mode>.local, if present")
+	} else {
+		flags.StringArrayVarP(&envFiles, "env-file", "e", []string{},
+			"Path to an env file; may be repeated")
+		flags.StringVar(&envMode, "mode", "",
+			"Load .env, .env.local, .env.<mode>, and .env.<mode>.local, if present")
+	}
+
+	flags.BoolVar(&omitEnv, "omit-env", false, "Omit any existing runtime environment variables")
+	flags.BoolVarP(&noEnvFile, "no-env-file", "E", false, "Don't load any env files")
+}
+
+// addInlineEnvFlag adds the --env flag to commands that run other commands.
+func addInlineEnvFlag(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&inlineEnv, "env", []string{},
+		"Set KEY=VALUE for every command, or <label>:KEY=VALUE for a single command; may be repeated")
+}
+
+// resolveEnv loads env files and applies --env, returning the variables for
+// every command and the overrides for each command. Each command may be
+// addressed by any of its targets, such as its label. See loadEnv for layered.
+func resolveEnv(targets [][]string, layered bool) ([]string, [][]string, error) {
+	vars, err := loadEnv(layered)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env := konk.EnvPairs(vars)
+	commandEnv := make([][]string, len(targets))
+
+	for _, flag := range inlineEnv {
+		pair, target, err := parseInlineEnv(flag)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if target == "" {
+			env = append(env, pair)
+			continue
+		}
+
+		matched, err := matchTarget(targets, target, "--env", flag)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, i := range matched {
+			commandEnv[i] = append(commandEnv[i], pair)
+		}
+	}
+
+	return env, commandEnv, nil
+}
+
+// parseInlineEnv parses an --env value, KEY=VALUE or <target>:KEY=VALUE. The
+// target is cut from before the "=", since values may contain ":".
+func parseInlineEnv(flag string) (string, string, error) {
+	name, value, ok := strings.Cut(flag, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid --env %q: expected KEY=VALUE", flag)
+	}
+
+	target, name, _ := cutTarget(name)
+
+	if name == "" {
+		return "", "", fmt.Errorf("invalid --env %q: missing variable name", flag)
+	}
+
+	return name + "=" + value, target, nil
+}
+
+// loadEnv loads the env files selected by the env flags. Explicitly given env
+// files must exist, while the conventional layered files are skipped if they
+// don't. Unless layered is set, the layered files are only loaded for --mode.
+func loadEnv(layered bool) ([]konk.EnvVar, error) {
+	if noEnvFile || (!layered && len(envFiles) == 0 && envMode == "") {
+		return []konk.EnvVar{}, nil
+	}
+
+	if len(envFiles) > 0 && envMode != "" {
+		return nil, errors.New("--mode cannot be used with --env-file")
+	}
+
+	files := konk.LayeredEnvFiles(envMode)
+
+	if len(envFiles) > 0 {
+		files = make([]konk.EnvFile, len(envFiles))
+		for i, path := range envFiles {
+			files[i] = konk.EnvFile{Path: path, Optional: false}
+		}
+	}
+
+	// Values may refer to the runtime environment, unless it's omitted.
+	var lookup konk.EnvLookupFunc
+	if !omitEnv {
+		lookup = os.LookupEnv
+	}
+
+	return konk.LoadEnv(files, lookup)
+}
+
+// quoteEnvValue double-quotes a value if it couldn't otherwise be read back
+// from an env file as-is.
+func quoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t\r\n\"'`#$\\") {
+		return value
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+	return `"` + r.Replace(value) + `"`
+}
+
+func init() {
+	envCommand.Flags().StringVarP(&workingDirectory,
+		"working-directory", "w", "", "set the working directory")
+	addEnvFlags(envCommand.Flags(), true)
+	rootCmd.AddCommand(&envCommand)
+}
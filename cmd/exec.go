@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jclem/konk/konk"
+	"github.com/spf13/cobra"
+)
+
+var execCommand = cobra.Command{
+	Use:   "exec <command> [args...]",
+	Short: "Run a single command with the environment konk proc gives its processes",
+	Example: `# Open a Rails console with variables from .env and .env.local
+
+konk exec -- rails console
+
+# Run database migrations with variables from .env.test and PORT set to 3000
+
+konk exec --mode test --port 3000 -- rake db:migrate`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if workingDirectory != "" {
+			if err := os.Chdir(workingDirectory); err != nil {
+				return fmt.Errorf("changing working directory: %w", err)
+			}
+		}
+
+		env, _, err := resolveEnv(nil, true)
+		if err != nil {
+			return err
+		}
+
+		basePort, err := resolveBasePort(cmd, env)
+		if err != nil {
+			return err
+		}
+
+		return konk.Exec(konk.ExecConfig{
+			Args:    args,
+			Env:     append(env, konk.PortEnv(basePort)...),
+			OmitEnv: omitEnv,
+		})
+	},
+}
+
+func init() {
+	// Flags after the command belong to it, e.g. "konk exec rails console -e test".
+	execCommand.Flags().SetInterspersed(false)
+
+	execCommand.Flags().StringVarP(&workingDirectory,
+		"working-directory", "w", "", "set the working directory for the command")
+	execCommand.Flags().IntVar(&port, "port", 0, "port to assign to PORT and KONK_PORT (default $PORT or 5000)")
+	addEnvFlags(execCommand.Flags(), true)
+	addInlineEnvFlag(execCommand.Flags())
+	rootCmd.AddCommand(&execCommand)
+}
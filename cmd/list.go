@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/jclem/konk/konk"
+	"github.com/spf13/cobra"
+)
+
+var listJSON bool
+
+// taskList is the tasks declared in one source, such as package.json.
+type taskList struct {
+	Source string     `json:"source"`
+	Tasks  []listTask `json:"tasks"`
+}
+
+type listTask struct {
+	Name string `json:"name"`
+	// Command is the task's command, for sources that declare one.
+	Command string `json:"command,omitempty"`
+}
+
+var listCommand = cobra.Command{
+	Use:     "list [pattern...]",
+	Aliases: []string{"ls"},
+	Short:   "List the scripts, Procfile processes, and tasks konk can run (alias: ls)",
+	Example: `# List everything konk can run in this directory
+
+konk list
+
+# List the package.json scripts and tasks that -n "check:*" would run
+
+konk list "check:*"`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if workingDirectory != "" {
+			if err := os.Chdir(workingDirectory); err != nil {
+				return fmt.Errorf("changing working directory: %w", err)
+			}
+		}
+
+		lists := collectTaskLists(cmd.ErrOrStderr())
+
+		lists = filterTaskLists(lists, args)
+
+		if listJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+
+			if err := enc.Encode(lists); err != nil {
+				return fmt.Errorf("writing tasks: %w", err)
+			}
+
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+
+		for i, list := range lists {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+
+			fmt.Fprintln(w, list.Source)
+
+			for _, task := range list.Tasks {
+				if task.Command == "" {
+					fmt.Fprintf(w, "  %s\n", task.Name)
+				} else {
+					fmt.Fprintf(w, "  %s\t%s\n", task.Name, task.Command)
+				}
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("writing tasks: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// collectTaskLists reads every source of tasks in the working directory,
+// skipping those that don't exist. A source that can't be read is reported to
+// warn and skipped, so that the others are still listed.
+func collectTaskLists(warn io.Writer) []taskList {
+	lists := []taskList{}
+
+	skip := func(source string, err error) bool {
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(warn, "skipping %s: %s\n", source, err)
+		}
+
+		return err != nil
+	}
+
+	pkgJSON, err := konk.ReadPackageJSON(".")
+	if !skip("package.json", err) {
+		list := taskList{Source: "package.json", Tasks: []listTask{}}
+		for _, script := range pkgJSON.Scripts {
+			list.Tasks = append(list.Tasks, listTask{Name: script.Name, Command: script.Command})
+		}
+
+		lists = append(lists, list)
+	}
+
+	entries, err := konk.ReadProcfile(procfile)
+	if !skip(procfile, err) {
+		list := taskList{Source: procfile, Tasks: []listTask{}}
+		for _, entry := range entries {
+			list.Tasks = append(list.Tasks, listTask{Name: entry.Name, Command: entry.Command})
+		}
+
+		lists = append(lists, list)
+	}
+
+	for _, tf := range taskFlags {
+		tasks, err := tf.source.Tasks(".")
+		if skip(tf.source.Name(), err) {
+			continue
+		}
+
+		list := taskList{Source: tf.source.Name(), Tasks: []listTask{}}
+		for _, task := range tasks {
+			list.Tasks = append(list.Tasks, listTask{Name: task.Name, Command: task.Command})
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists
+}
+
+// filterTaskLists keeps the tasks matching any of patterns, which are globs as
+// given to -n, dropping sources left empty. With no patterns, it keeps every
+// task.
+func filterTaskLists(lists []taskList, patterns []string) []taskList {
+	if len(patterns) == 0 {
+		return lists
+	}
+
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		res[i] = scriptPattern(pattern)
+	}
+
+	filtered := []taskList{}
+
+	for _, list := range lists {
+		list.Tasks = slices.DeleteFunc(list.Tasks, func(task listTask) bool {
+			return !slices.ContainsFunc(res, func(re *regexp.Regexp) bool { return re.MatchString(task.Name) })
+		})
+
+		if len(list.Tasks) > 0 {
+			filtered = append(filtered, list)
+		}
+	}
+
+	return filtered
+}
+
+func init() {
+	listCommand.Flags().StringVarP(&workingDirectory,
+		"working-directory", "w", "", "set the working directory")
+	listCommand.Flags().StringVarP(&procfile, "procfile", "p", "Procfile", "Path to the Procfile")
+	listCommand.Flags().BoolVar(&listJSON, "json", false, "print the tasks as JSON")
+	rootCmd.AddCommand(&listCommand)
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/jclem/konk/konk"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// stageMark records how many commands had been given where a stage starts.
+type stageMark struct {
+	parallel bool
+	args     int
+	// values counts the values given to each flag so far.
+	values map[string]int
+}
+
+// scanStages finds where each stage given to "konk run" starts, at each --then
+// and --parallel in its arguments as given on the command line. The arguments
+// are split as when cobra parsed them, but no flag is set again. The last mark
+// is where the commands end.
+func scanStages(cmd *cobra.Command) ([]stageMark, error) {
+	_, raw, err := cmd.Root().Find(os.Args[1:])
+	if err != nil {
+		return nil, fmt.Errorf("finding arguments: %w", err)
+	}
+
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(cmd.Flags())
+
+	values := map[string]int{}
+	marks := []stageMark{{parallel: false, args: 0, values: map[string]int{}}}
+
+	err = flags.ParseAll(raw, func(flag *pflag.Flag, value string) error {
+		values[flag.Name]++
+
+		if flag.Name != "then" && flag.Name != "parallel" {
+			return nil
+		}
+
+		// As with any other bool flag, "--then=false" is as if it weren't
+		// given. Cobra has already rejected values that aren't bools.
+		if start, _ := strconv.ParseBool(value); !start {
+			return nil
+		}
+
+		marks = append(marks, stageMark{parallel: flag.Name == "parallel", args: len(flags.Args()), values: maps.Clone(values)})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing arguments: %w", err)
+	}
+
+	args := len(flags.Args())
+	if dash := flags.ArgsLenAtDash(); dash >= 0 {
+		args = dash
+	}
+
+	return append(marks, stageMark{parallel: false, args: args, values: values}), nil
+}
+
+// hasCommands reports whether any commands were given between mark and end.
+func (mark stageMark) hasCommands(end stageMark) bool {
+	if end.args > mark.args || end.values["npm"] > mark.values["npm"] {
+		return true
+	}
+
+	return slices.ContainsFunc(taskFlags, func(tf taskFlag) bool {
+		return end.values[tf.name] > mark.values[tf.name]
+	})
+}
+
+// stage is a group of commands in a pipeline, which run concurrently if the
+// stage is parallel and otherwise one after another.
+type stage struct {
+	parallel bool
+	list     *commandList
+}
+
+// collectStages splits the commands given to "konk run" into stages at each
+// --then and --parallel. A mark directly after another, as in "--then
+// --parallel", only sets whether the stage it starts is parallel.
+func collectStages(cmd *cobra.Command, args []string, forwarded []string) ([]stage, error) {
+	scanned, err := scanStages(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	marks := []stageMark{scanned[0]}
+
+	for i, mark := range scanned[1:] {
+		if last := marks[len(marks)-1]; !last.hasCommands(mark) {
+			// One before any command only sets whether the first stage is
+			// parallel, but one after the last command starts an empty stage.
+			if i == len(scanned)-2 && len(marks) > 1 {
+				return nil, errors.New("empty stage: --then and --parallel must be followed by a command")
+			}
+
+			marks[len(marks)-1].parallel = mark.parallel || last.parallel
+
+			continue
+		}
+
+		marks = append(marks, mark)
+	}
+
+	all := flagSelection()
+	stages := make([]stage, 0, len(marks)-1)
+
+	// Each stage's commands are collected as if they had been given on their own.
+	for i, mark := range marks[:len(marks)-1] {
+		end := marks[i+1]
+
+		sel := commandSelection{
+			npm:   all.npm[mark.values["npm"]:end.values["npm"]],
+			tasks: make([][]string, len(taskFlags)),
+			dirs:  all.dirs[mark.values["cwd"]:end.values["cwd"]],
+		}
+
+		hasSpecs := len(sel.npm) > 0
+
+		for j, tf := range taskFlags {
+			sel.tasks[j] = all.tasks[j][mark.values[tf.name]:end.values[tf.name]]
+			hasSpecs = hasSpecs || len(sel.tasks[j]) > 0
+		}
+
+		stageForwarded := forwarded
+		if !hasSpecs {
+			stageForwarded = nil
+		}
+
+		list, err := collectCommands(args[mark.args:end.args], stageForwarded, sel)
+		if err != nil {
+			return nil, err
+		}
+
+		stages = append(stages, stage{parallel: mark.parallel, list: list})
+	}
+
+	return stages, nil
+}
+
+// runPipeline runs the stages given to "konk run" one after another, stopping
+// at the first that fails unless --continue-on-error is set.
+func runPipeline(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	if workingDirectory != "" {
+		if err := os.Chdir(workingDirectory); err != nil {
+			return fmt.Errorf("changing working directory: %w", err)
+		}
+	}
+
+	args, forwarded := splitForwardedArgs(cmd, args)
+
+	stages, err := collectStages(cmd, args, forwarded)
+	if err != nil {
+		return err
+	}
+
+	var provided []string
+	for _, s := range stages {
+		provided = append(provided, s.list.provided...)
+	}
+
+	if len(forwarded) > 0 && len(provided) == len(args) {
+		return errors.New("arguments after -- are only passed to -n scripts and tasks")
+	}
+
+	if len(names) > 0 && len(names) != len(provided) {
+		return errors.New("number of names must match number of commands")
+	}
+
+	// Labels are collected across every stage, so they line up throughout.
+	labels := collectLabels(provided)
+	targets := labelTargets(labels)
+
+	env, commandEnv, err := resolveEnv(targets, false)
+	if err != nil {
+		return err
+	}
+
+	commandShells, err := resolveShells(targets)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return printPipelinePlan(cmd, stages, labels, commandEnv, commandShells)
+	}
+
+	var errStage error
+
+	for _, s := range stages {
+		n := len(s.list.runnable)
+		stageLabels, stageEnv := labels[:n], commandEnv[:n]
+		labels, commandEnv = labels[n:], commandEnv[n:]
+
+		var stageShells []string
+		if commandShells != nil {
+			stageShells, commandShells = commandShells[:n], commandShells[n:]
+		}
+
+		var commands []*konk.Command
+
+		if s.parallel {
+			commands, err = konk.RunConcurrently(ctx, konk.RunConcurrentlyConfig{
+				Commands:        s.list.runnable,
+				Labels:          stageLabels,
+				Names:           nil,
+				EnvVars:         env,
+				CommandEnv:      stageEnv,
+				Dirs:            s.list.dirs,
+				OmitEnv:         omitEnv,
+				AggregateOutput: false,
+				OutputOrder:     konk.OrderCompletion,
+				GroupHeader:     false,
+				ContinueOnError: continueOnError,
+				NoColor:         noColor,
+				NoShell:         noShell,
+				Expand:          expand,
+				Shells:          stageShells,
+				ShellOpts:       shellOpts,
+				ControlSocket:   "",
+				Interactive:     false,
+				DependsOn:       s.list.dependsOn,
+			})
+		} else {
+			commands, err = konk.RunSerially(ctx, konk.RunSeriallyConfig{
+				Commands:        s.list.runnable,
+				Labels:          stageLabels,
+				EnvVars:         env,
+				CommandEnv:      stageEnv,
+				Dirs:            s.list.dirs,
+				OmitEnv:         omitEnv,
+				ContinueOnError: continueOnError,
+				NoColor:         noColor,
+				NoShell:         noShell,
+				Expand:          expand,
+				Shells:          stageShells,
+				ShellOpts:       shellOpts,
+			})
+		}
+
+		debugCommands(ctx, commands)
+
+		if err != nil && (!continueOnError || errors.Is(err, konk.ErrInterrupted)) {
+			return fmt.Errorf("running commands: %w", err)
+		} else if err != nil {
+			errStage = fmt.Errorf("running commands: %w", err)
+		}
+	}
+
+	return errStage
+}
+
+// printPipelinePlan prints the stages given to "konk run", for --dry-run.
+func printPipelinePlan(cmd *cobra.Command, stages []stage, labels []string, commandEnv [][]string, shells []string) error {
+	plan := make([]planStage, len(stages))
+
+	for i, s := range stages {
+		how := "serially"
+		if s.parallel {
+			how = "concurrently"
+		}
+
+		n := len(s.list.runnable)
+
+		var stageShells []string
+		if shells != nil {
+			stageShells, shells = shells[:n], shells[n:]
+		}
+
+		plan[i] = newPlanStage(how, s.list, labels[:n], commandEnv[:n], stageShells)
+		labels, commandEnv = labels[n:], commandEnv[n:]
+	}
+
+	return printPlan(cmd.OutOrStdout(), plan, false)
+}
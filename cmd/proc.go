@@ -1,25 +1,50 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/jclem/konk/konk"
 	"github.com/spf13/cobra"
 )
 
-var envFile string
-var noEnvFile bool
 var procfile string
-var omitEnv bool
+var formation string
+var port int
+var except []string
+var procDirs []string
 
 var procCommand = cobra.Command{
-	Use:     "proc",
+	Use:     "proc [name...]",
 	Aliases: []string{"p"},
 	Short:   "Run commands defined in a Procfile (alias: p)",
-	RunE: func(cmd *cobra.Command, _ []string) error {
+	Example: `# Run every process in the Procfile
+
+konk proc
+
+# Run only the web and worker processes
+
+konk proc web worker
+
+# Run every process except the clock process
+
+konk proc --except clock
+
+# Run two web processes and three worker processes
+
+konk proc -m web=2,worker=3
+
+# Assign ports from 3000: web.1 gets 3000, web.2 3001, and worker.1 3100
+
+konk proc -m web=2,worker=1 --port 3000
+
+# Run the web process in the web directory
+
+konk proc --cwd web:web`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
 		if debug {
@@ -32,70 +57,123 @@ var procCommand = cobra.Command{
 			}
 		}
 
-		procfile, err := os.Open(procfile)
+		entries, err := konk.ReadProcfile(procfile)
 		if err != nil {
-			return fmt.Errorf("opening procfile: %w", err)
+			return err
+		}
+
+		form := konk.Formation{}
+		if formation != "" {
+			form, err = konk.ParseFormation(formation)
+			if err != nil {
+				return err
+			}
 		}
-		defer procfile.Close()
 
-		procfileMap := map[string]string{}
-		scanner := bufio.NewScanner(procfile)
+		processes, err := form.Processes(entries)
+		if err != nil {
+			return err
+		}
 
-		for scanner.Scan() {
-			procfileLine := strings.TrimSpace(scanner.Text())
+		processes, err = selectProcesses(entries, processes, args, except)
+		if err != nil {
+			return err
+		}
 
-			if procfileLine == "" {
-				continue
-			}
+		targets := make([][]string, len(processes))
+		for i, proc := range processes {
+			targets[i] = []string{proc.Entry.Name, proc.Name()}
+		}
 
-			line := strings.SplitN(procfileLine, ":", 2)
-			procfileMap[strings.TrimSpace(line[0])] = strings.TrimSpace(line[1])
+		dirs, err := resolveProcDirs(targets, procDirs)
+		if err != nil {
+			return err
 		}
 
-		envLines := []string{}
-		if !noEnvFile {
-			envFile, err := os.ReadFile(envFile)
-			if err != nil {
-				return fmt.Errorf("reading env file: %w", err)
-			}
-			envLines = strings.Split(string(envFile), "\n")
+		env, inlineCommandEnv, err := resolveEnv(targets, true)
+		if err != nil {
+			return err
+		}
+
+		shells, err := resolveShells(targets)
+		if err != nil {
+			return err
 		}
 
-		commandStrings := make([]string, 0, len(procfileMap))
-		commandLabels := make([]string, 0, len(procfileMap))
+		basePort, err := resolveBasePort(cmd, env)
+		if err != nil {
+			return err
+		}
+
+		commandStrings := make([]string, 0, len(processes))
+		commandLabels := make([]string, 0, len(processes))
+		commandNames := make([]string, 0, len(processes))
+		commandEnv := make([][]string, 0, len(processes))
+		commandDirs := make([]string, 0, len(processes))
+		commandShells := make([]string, 0, len(processes))
+
+		for i, proc := range processes {
+			// Without a formation, each process type runs once, so there is no
+			// need to tell instances apart.
+			name := proc.Entry.Name
+			if formation != "" {
+				name = proc.Name()
+			}
 
-		for label, command := range procfileMap {
-			commandStrings = append(commandStrings, command)
+			commandStrings = append(commandStrings, proc.Entry.Command)
+			commandNames = append(commandNames, name)
+			commandEnv = append(commandEnv, append(proc.Env(basePort), inlineCommandEnv[i]...))
+			commandDirs = append(commandDirs, dirs[i])
+			if shells != nil {
+				commandShells = append(commandShells, shells[i])
+			}
 			if noLabel {
 				commandLabels = append(commandLabels, "")
 			} else {
-				commandLabels = append(commandLabels, label)
+				commandLabels = append(commandLabels, name)
 			}
 		}
 
 		if !noLabel {
-			var maxLabelLen int
+			commandLabels = alignLabels(commandLabels)
+		}
 
-			for _, label := range commandLabels {
-				if len(label) > maxLabelLen {
-					maxLabelLen = len(label)
+		if dryRun {
+			plan := planStage{how: "concurrently", commands: make([]planCommand, len(commandStrings))}
+			for i, command := range commandStrings {
+				plan.commands[i] = planCommand{
+					label:   strings.TrimSpace(commandLabels[i]),
+					command: command,
+					dir:     commandDirs[i],
+					env:     commandEnv[i],
+					shell:   planShell(commandShells, i),
+					after:   nil,
 				}
 			}
 
-			for i, label := range commandLabels {
-				commandLabels[i] = fmt.Sprintf("%s%s", label, strings.Repeat(" ", maxLabelLen-len(label)))
-			}
+			return printPlan(cmd.OutOrStdout(), []planStage{plan}, true)
 		}
 
 		commands, err := konk.RunConcurrently(ctx, konk.RunConcurrentlyConfig{
 			Commands:        commandStrings,
 			Labels:          commandLabels,
-			Env:             envLines,
+			Names:           commandNames,
+			EnvVars:         env,
+			CommandEnv:      commandEnv,
+			Dirs:            commandDirs,
 			OmitEnv:         omitEnv,
 			AggregateOutput: false,
+			OutputOrder:     konk.OrderCompletion,
+			GroupHeader:     false,
 			ContinueOnError: continueOnError,
 			NoColor:         noColor,
 			NoShell:         noShell,
+			Expand:          expand,
+			Shells:          commandShells,
+			ShellOpts:       shellOpts,
+			ControlSocket:   controlSocketPath(),
+			Interactive:     interactive,
+			DependsOn:       nil,
 		})
 
 		debugCommands(ctx, commands)
@@ -108,18 +186,87 @@ var procCommand = cobra.Command{
 	},
 }
 
+// selectProcesses filters processes down to those named in only (if any are),
+// excluding those named in except. Processes keep their port offsets, so each
+// is assigned the same port as in a full run.
+func selectProcesses(entries []konk.ProcfileEntry, processes []konk.Process, only, except []string) ([]konk.Process, error) {
+	for _, name := range slices.Concat(only, except) {
+		if !slices.ContainsFunc(entries, func(e konk.ProcfileEntry) bool { return e.Name == name }) {
+			return nil, fmt.Errorf("unknown process type %q", name)
+		}
+	}
+
+	return slices.DeleteFunc(processes, func(p konk.Process) bool {
+		return (len(only) > 0 && !slices.Contains(only, p.Entry.Name)) || slices.Contains(except, p.Entry.Name)
+	}), nil
+}
+
+// resolveProcDirs applies --cwd, returning the working directory for each
+// process. Unlike with "konk run", each directory must name the processes it
+// applies to, as <type>:DIR, since processes aren't given in order.
+func resolveProcDirs(targets [][]string, flags []string) ([]string, error) {
+	for _, flag := range flags {
+		if _, _, ok := cutTarget(flag); !ok {
+			return nil, fmt.Errorf("invalid --cwd %q: expected <type>:DIR", flag)
+		}
+	}
+
+	return resolveCommandDirs(targets, flags)
+}
+
+// resolveBasePort returns the port assigned to the first process: --port if
+// given, otherwise PORT from the env file or the environment.
+func resolveBasePort(cmd *cobra.Command, env []string) (int, error) {
+	if cmd.Flags().Changed("port") {
+		return port, nil
+	}
+
+	p, ok := konk.LookupEnv(env, "PORT")
+	if !ok {
+		p, ok = os.LookupEnv("PORT")
+	}
+
+	if !ok {
+		return konk.DefaultBasePort, nil
+	}
+
+	basePort, err := strconv.Atoi(p)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PORT %q: %w", p, err)
+	}
+
+	return basePort, nil
+}
+
 func init() {
 	procCommand.Flags().StringVarP(&workingDirectory,
 		"working-directory", "w", "", "set the working directory for all commands")
+	procCommand.Flags().StringArrayVar(&procDirs, "cwd", []string{},
+		"working directory for a process type, as <type>:DIR; may be repeated")
 	procCommand.Flags().BoolVarP(&continueOnError,
 		"continue-on-error", "c", false, "continue running commands after a failure")
 	procCommand.Flags().BoolVarP(&noShell, "no-subshell", "S", false, "do not run commands in a subshell")
 	procCommand.Flags().BoolVarP(&noColor, "no-color", "C", false, "do not colorize label output")
+	procCommand.Flags().BoolVar(&dryRun, "dry-run", false, "print what would run without running anything")
 
 	procCommand.Flags().StringVarP(&procfile, "procfile", "p", "Procfile", "Path to the Procfile")
-	procCommand.Flags().StringVarP(&envFile, "env-file", "e", ".env", "Path to the env file")
-	procCommand.Flags().BoolVar(&omitEnv, "omit-env", false, "Omit any existing runtime environment variables")
-	procCommand.Flags().BoolVarP(&noEnvFile, "no-env-file", "E", false, "Don't load the env file")
+	procCommand.Flags().StringVarP(&formation, "formation", "m", "",
+		"number of instances of each process to run, e.g. \"web=2,worker=3\" or \"all=2\"")
+	procCommand.Flags().StringArrayVarP(&except, "except", "x", []string{}, "process type to exclude")
+	procCommand.Flags().IntVar(&port, "port", 0,
+		"base port; each process type is offset by 100 and each instance by 1 (default $PORT or 5000)")
+	addEnvFlags(procCommand.Flags(), true)
+	addInlineEnvFlag(procCommand.Flags())
+	addShellFlags(procCommand.Flags())
 	procCommand.Flags().BoolVarP(&noLabel, "no-label", "B", false, "do not attach label/prefix to output")
+	procCommand.Flags().IntVar(&maxLabelWidth,
+		"max-label-width", 0, "truncate labels longer than this many columns (0 for no limit)")
+	procCommand.Flags().BoolVar(&control, "control", false, "serve a control API on "+konk.DefaultControlSocket)
+	procCommand.Flags().BoolVarP(&interactive, "interactive", "i", false, "accept commands such as \"rs <name>\" on stdin")
+
+	procCommand.ValidArgsFunction = completeWith(procNames)
+	_ = procCommand.RegisterFlagCompletionFunc("except", completeWith(procNames))
+	_ = procCommand.RegisterFlagCompletionFunc("cwd", completeProcDir)
+
 	rootCmd.AddCommand(&procCommand)
 }
@@ -1,130 +1,598 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"sort"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/jclem/konk/konk"
+	"github.com/mattn/go-runewidth"
 	"github.com/spf13/cobra"
 )
 
 var cmdAsLabel bool
 var npmCmds []string
 var runWithBun bool
+var packageManager string
 var names []string
+var maxLabelWidth int
+var commandDirs []string
+var workspaces bool
+var topological bool
+var direct bool
+var makeTargets []string
+var justRecipes []string
+var taskfileTasks []string
+var denoTasks []string
+
+// taskFlag is a flag that selects tasks from a task source.
+type taskFlag struct {
+	name   string
+	source konk.TaskSource
+	values *[]string
+}
+
+// taskFlags are the flags that select tasks from sources other than
+// package.json, in the order their commands run.
+var taskFlags = []taskFlag{
+	{"make", konk.MakeTasks, &makeTargets},
+	{"just", konk.JustTasks, &justRecipes},
+	{"task", konk.TaskfileTasks, &taskfileTasks},
+	{"deno", konk.DenoTasks, &denoTasks},
+}
+
+// commandSelection is the values of the flags that select commands: -n, the
+// task flags, and --cwd.
+type commandSelection struct {
+	npm []string
+	// tasks holds the values of each of taskFlags.
+	tasks [][]string
+	dirs  []string
+}
+
+// flagSelection returns the commands selected by the flags as given.
+func flagSelection() commandSelection {
+	tasks := make([][]string, len(taskFlags))
+	for i, tf := range taskFlags {
+		tasks[i] = *tf.values
+	}
+
+	return commandSelection{npm: npmCmds, tasks: tasks, dirs: commandDirs}
+}
 
 var runCommand = cobra.Command{
-	Use:     "run <subcommand>",
+	Use:     "run [<command...> [--then|--parallel <command...>]...]",
 	Aliases: []string{"r"},
 	Short:   "Run commands serially or concurrently (alias: r)",
-	RunE: func(cmd *cobra.Command, _ []string) error {
-		_ = cmd.Help()
-		os.Exit(1)
-		return nil
+	Long: `Run commands serially or concurrently (alias: r)
+
+Given commands rather than a subcommand, run runs a pipeline of stages, one
+after another. The commands in a stage run one after another, unless the stage
+starts with --parallel, in which case they run concurrently. --then starts a
+new stage.`,
+	Example: `# Clean, then build two packages concurrently, then deploy
+
+konk run "make clean" --then --parallel "make a" "make b" --then "make deploy"
+
+# Run the clean script, then the build:* scripts one after another, then the
+# serve and watch scripts concurrently
+
+konk run -n clean -n "build:*" --parallel -n serve -n watch`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && len(npmCmds) == 0 && !slices.ContainsFunc(taskFlags, func(tf taskFlag) bool {
+			return len(*tf.values) > 0
+		}) {
+			_ = cmd.Help()
+			os.Exit(1)
+		}
+
+		if debug {
+			cmd.DebugFlags()
+		}
+
+		return runPipeline(cmd, args)
 	},
 }
 
 func init() {
 	runCommand.PersistentFlags().StringVarP(&workingDirectory,
 		"working-directory", "w", "", "set the working directory for all commands")
+	runCommand.PersistentFlags().StringArrayVar(&commandDirs, "cwd", []string{},
+		"working directory for a command, as <command>:DIR, or DIR for the next command without one, in order: "+
+			"arguments, then -n scripts, then tasks")
 	runCommand.PersistentFlags().BoolVarP(&continueOnError,
 		"continue-on-error", "c", false, "continue running commands after a failure")
 	runCommand.PersistentFlags().BoolVarP(&noShell, "no-subshell", "S", false, "do not run commands in a subshell")
 	runCommand.PersistentFlags().BoolVarP(&noColor, "no-color", "C", false, "do not colorize label output")
+	runCommand.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print what would run without running anything")
 
 	runCommand.PersistentFlags().BoolVarP(&cmdAsLabel, "command-as-label", "L", false, "use each command as its own label")
-	runCommand.PersistentFlags().StringArrayVarP(&npmCmds, "npm", "n", []string{}, "npm command")
-	runCommand.PersistentFlags().BoolVarP(&runWithBun, "bun", "b", false, "Run npm commands with Bun")
+	runCommand.PersistentFlags().StringArrayVarP(&npmCmds, "npm", "n", []string{},
+		"npm command, or a glob such as \"lint:*\" or \"test:**\" (prefix with ! to exclude matches)")
+	runCommand.PersistentFlags().BoolVarP(&runWithBun, "bun", "b", false,
+		"Run npm commands with Bun (same as --package-manager bun)")
+	runCommand.PersistentFlags().StringVar(&packageManager, "package-manager", "",
+		"package manager to run npm commands with: npm, pnpm, yarn, or bun (default detected from lockfiles)")
+	runCommand.PersistentFlags().StringArrayVar(&makeTargets, "make", []string{},
+		"Makefile target, or a glob such as \"build-*\" (arguments after -- are passed as ARGS)")
+	runCommand.PersistentFlags().StringArrayVar(&justRecipes, "just", []string{},
+		"justfile recipe, or a glob such as \"test-*\"")
+	runCommand.PersistentFlags().StringArrayVar(&taskfileTasks, "task", []string{},
+		"Taskfile task, or a glob such as \"lint:*\"")
+	runCommand.PersistentFlags().StringArrayVar(&denoTasks, "deno", []string{},
+		"deno.json task, or a glob such as \"dev:*\"")
+	runCommand.PersistentFlags().BoolVar(&direct, "direct", false,
+		"run npm commands' scripts directly with /bin/sh, without starting the package manager")
+	runCommand.PersistentFlags().BoolVar(&workspaces, "workspaces", false,
+		"run npm commands in every workspace package that defines them, labeled by package name")
+	runCommand.PersistentFlags().BoolVar(&topological, "topological", false,
+		"with --workspaces, run each package's scripts after those of the packages it depends on")
 	runCommand.PersistentFlags().StringArrayVarP(&names, "label", "l", []string{}, "label prefix for the command")
 	runCommand.PersistentFlags().BoolVarP(&noLabel, "no-label", "B", false, "do not attach label/prefix to output")
+	addEnvFlags(runCommand.PersistentFlags(), false)
+	addInlineEnvFlag(runCommand.PersistentFlags())
+	addShellFlags(runCommand.PersistentFlags())
+	runCommand.PersistentFlags().IntVar(&maxLabelWidth,
+		"max-label-width", 0, "truncate labels longer than this many columns (0 for no limit)")
+
+	// Stages are found by scanStages, from where these are given.
+	runCommand.Flags().Bool("then", false, "start a new stage, whose commands run one after another")
+	runCommand.Flags().Bool("parallel", false, "start a new stage, whose commands run concurrently")
+
+	_ = runCommand.RegisterFlagCompletionFunc("npm", completeWith(scriptNames))
+	_ = runCommand.RegisterFlagCompletionFunc("make", completeWith(taskNames(konk.MakeTasks)))
+	_ = runCommand.RegisterFlagCompletionFunc("just", completeWith(taskNames(konk.JustTasks)))
+	_ = runCommand.RegisterFlagCompletionFunc("task", completeWith(taskNames(konk.TaskfileTasks)))
+	_ = runCommand.RegisterFlagCompletionFunc("deno", completeWith(taskNames(konk.DenoTasks)))
+	_ = runCommand.RegisterFlagCompletionFunc("label", completeWith(labelNames))
+	_ = runCommand.RegisterFlagCompletionFunc("package-manager", completeWith(packageManagerNames))
+	_ = runCommand.RegisterFlagCompletionFunc("cwd", completeDirs)
+
 	rootCmd.AddCommand(&runCommand)
 }
 
-func collectCommands(args []string) ([]string, []string, error) {
-	// The commands as provided by the user
-	providedCommands := []string{}
+// commandList is the set of commands to run, resolved from arguments and -n.
+type commandList struct {
+	// provided holds each command as given by the user, e.g. a script name.
+	provided []string
+	// runnable holds each command to run, e.g. prefixed with "npm run".
+	runnable []string
+	dirs     []string
+	// dependsOn holds, for each command, the indices of the commands it waits
+	// for with --topological.
+	dependsOn [][]int
+}
+
+func (l *commandList) add(provided, runnable, dir string) {
+	l.provided = append(l.provided, provided)
+	l.runnable = append(l.runnable, runnable)
+	l.dirs = append(l.dirs, dir)
+	l.dependsOn = append(l.dependsOn, nil)
+}
+
+// addScript adds a script or task, unless it already runs in the same
+// directory with the same arguments, as when more than one glob matches it.
+// It reports whether the script was added.
+func (l *commandList) addScript(provided, runnable, dir string) bool {
+	for i := range l.runnable {
+		if l.runnable[i] == runnable && l.dirs[i] == dir {
+			return false
+		}
+	}
+
+	l.add(provided, runnable, dir)
+
+	return true
+}
+
+// scriptSpec is a script given with -n, such as "test {1}".
+type scriptSpec struct {
+	// name is the script's name, or a glob matching script names.
+	name string
+	// given is the spec as given by the user, before placeholders are
+	// substituted.
+	given string
+	// args is the arguments to pass to the script, quoted for the shell.
+	args string
+}
+
+// provided returns the spec as given, with its name replaced by script, which
+// matched it.
+func (s scriptSpec) provided(script string) string {
+	return script + strings.TrimPrefix(s.given, s.name)
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([1-9][0-9]*|@|\*)\}`)
+
+// parseScriptSpec parses a -n value into a script and its arguments. Arguments
+// forwarded after "--" replace the placeholders {1}, {2}, ... (a single
+// argument), {@} (every argument), and {*} (every argument, as one), or, if
+// there are none, are appended.
+func parseScriptSpec(given string, forwarded []string) scriptSpec {
+	name, args, _ := strings.Cut(strings.TrimSpace(given), " ")
+	args = strings.TrimSpace(args)
+
+	if placeholderPattern.MatchString(args) {
+		args = placeholderPattern.ReplaceAllStringFunc(args, func(placeholder string) string {
+			switch key := placeholder[1 : len(placeholder)-1]; key {
+			case "@":
+				return quoteArgs(forwarded)
+			case "*":
+				return konk.ShellQuote(strings.Join(forwarded, " "))
+			default:
+				n, _ := strconv.Atoi(key)
+				if n > len(forwarded) {
+					return ""
+				}
+
+				return konk.ShellQuote(forwarded[n-1])
+			}
+		})
+	} else if len(forwarded) > 0 {
+		args = strings.TrimSpace(args + " " + quoteArgs(forwarded))
+	}
+
+	return scriptSpec{name: name, given: given, args: args}
+}
+
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = konk.ShellQuote(arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// parseScriptSpecs parses the values of a flag such as -n. Values given as
+// "!pattern" exclude matching scripts from every glob.
+func parseScriptSpecs(values []string, forwarded []string) ([]scriptSpec, []*regexp.Regexp) {
+	var specs []scriptSpec
+	var excludes []*regexp.Regexp
+
+	for _, value := range values {
+		if pattern, ok := strings.CutPrefix(value, "!"); ok {
+			excludes = append(excludes, scriptPattern(pattern))
+		} else {
+			specs = append(specs, parseScriptSpec(value, forwarded))
+		}
+	}
+
+	return specs, excludes
+}
+
+// collectCommands resolves the commands given as arguments, and those selected
+// with -n and with the task flags such as --make. Arguments forwarded after
+// "--" are passed to every script and task.
+func collectCommands(args []string, forwarded []string, sel commandSelection) (*commandList, error) {
+	specs, excludes := parseScriptSpecs(sel.npm, forwarded)
+
+	taskSpecs := make([][]scriptSpec, len(taskFlags))
+	taskExcludes := make([][]*regexp.Regexp, len(taskFlags))
+	numSpecs := len(specs)
+
+	for i := range taskFlags {
+		taskSpecs[i], taskExcludes[i] = parseScriptSpecs(sel.tasks[i], forwarded)
+		numSpecs += len(taskSpecs[i])
+	}
+
+	if len(forwarded) > 0 && numSpecs == 0 {
+		return nil, errors.New("arguments after -- are only passed to -n scripts and tasks")
+	}
+
+	if direct && noShell {
+		return nil, errors.New("--direct can't be combined with --no-subshell")
+	}
+
+	if workspaces && len(sel.dirs) > 0 {
+		return nil, errors.New("--cwd can't be combined with --workspaces")
+	}
+
+	if topological && !workspaces {
+		return nil, errors.New("--topological requires --workspaces")
+	}
+
+	// Each command given, before globs are matched, may be addressed by its
+	// index or as given.
+	targets := make([][]string, 0, len(args)+numSpecs)
+	for _, arg := range args {
+		targets = append(targets, []string{strconv.Itoa(len(targets)), arg})
+	}
+
+	for _, spec := range slices.Concat(append([][]scriptSpec{specs}, taskSpecs...)...) {
+		targets = append(targets, []string{strconv.Itoa(len(targets)), spec.given, spec.name})
+	}
+
+	dirs, err := resolveCommandDirs(targets, sel.dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	dirFor := func(i int) string {
+		return dirs[i]
+	}
+
+	//nolint:exhaustruct // Filled in by add.
+	list := &commandList{}
+
+	for i, cmd := range args {
+		list.add(cmd, cmd, dirFor(i))
+	}
+
+	if workspaces {
+		if err := collectWorkspaceScripts(list, specs, excludes); err != nil {
+			return nil, err
+		}
+	} else {
+		for i, spec := range specs {
+			if err := collectScripts(list, spec, excludes, dirFor(len(args)+i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Tasks follow -n scripts, so their --cwd directories come after those of
+	// the scripts.
+	next := len(args) + len(specs)
+
+	for i, tf := range taskFlags {
+		for _, spec := range taskSpecs[i] {
+			dir := dirFor(next)
+			next++
+
+			tasks, err := tf.source.Tasks(dir)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, task := range matchScripts(tasks.Names(), spec.name, taskExcludes[i]) {
+				list.addScript(spec.provided(task), tf.source.RunTask(task, spec.args), dir)
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// resolveCommandDirs applies flags, the values of --cwd, returning the working
+// directory for each command given. A directory given as <command>:DIR applies
+// to the command addressed by any of its targets. The others apply in order to
+// the commands without one, and any commands left over run in the current
+// directory.
+func resolveCommandDirs(targets [][]string, flags []string) ([]string, error) {
+	dirs := make([]string, len(targets))
+	targeted := make([]bool, len(targets))
+
+	var positional []string
+
+	for _, flag := range flags {
+		target, dir, ok := cutTarget(flag)
+		if !ok {
+			positional = append(positional, flag)
+			continue
+		}
+
+		if target == "" || dir == "" {
+			return nil, fmt.Errorf("invalid --cwd %q: expected DIR or <command>:DIR", flag)
+		}
+
+		matched, err := matchTarget(targets, target, "--cwd", flag)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range matched {
+			dirs[i], targeted[i] = dir, true
+		}
+	}
+
+	for i := range dirs {
+		if len(positional) == 0 {
+			break
+		}
+
+		if !targeted[i] {
+			dirs[i], positional = positional[0], positional[1:]
+		}
+	}
+
+	if len(positional) > 0 {
+		return nil, errors.New("more --cwd directories than commands")
+	}
+
+	return dirs, nil
+}
+
+// collectScripts adds a command for each script in dir's package.json that
+// spec names.
+func collectScripts(list *commandList, spec scriptSpec, excludes []*regexp.Regexp, dir string) error {
+	pkgJSON, err := konk.ReadPackageJSON(dir)
+	if err != nil {
+		return err
+	}
 
-	// "Resolved" commands (e.g. prefixed with "bun run", etc.)
-	runnableCommands := []string{}
+	for _, script := range matchScripts(pkgJSON.Scripts.Names(), spec.name, excludes) {
+		runnable, err := scriptCommand(pkgJSON, dir, script, spec.args)
+		if err != nil {
+			return err
+		}
 
-	for _, cmd := range args {
-		providedCommands = append(providedCommands, cmd)
-		runnableCommands = append(runnableCommands, cmd)
+		list.addScript(spec.provided(script), runnable, dir)
 	}
 
-	var scripts []string
+	return nil
+}
 
-	if len(npmCmds) > 0 {
-		var err error
-		scripts, err = getPackageJSONScripts()
+// collectWorkspaceScripts adds a command for each script that matches
+// specs in each workspace package. Each command is labeled with its
+// package's name, or with the package and script names if the package runs
+// more than one script.
+func collectWorkspaceScripts(list *commandList, specs []scriptSpec, excludes []*regexp.Regexp) error {
+	ws, err := konk.FindWorkspaces(".")
+	if err != nil {
+		return err
+	}
+
+	if topological {
+		ws, err = konk.SortWorkspaces(ws)
 		if err != nil {
-			return nil, nil, err
+			return err
+		}
+	}
+
+	first := len(list.runnable)
+	pkgNames := []string{}
+	scriptNames := []string{}
+
+	for _, spec := range specs {
+		if !strings.ContainsAny(spec.name, "*?") && !slices.ContainsFunc(ws, func(w konk.Workspace) bool {
+			_, ok := w.Package.Scripts.Lookup(spec.name)
+			return ok
+		}) {
+			return fmt.Errorf("no workspace defines script %q", spec.name)
 		}
 	}
 
-	for _, cmd := range npmCmds {
-		if strings.HasSuffix(cmd, "*") {
-			prefix := strings.TrimSuffix(cmd, "*")
+	for _, w := range ws {
+		for _, spec := range specs {
+			for _, script := range matchScripts(w.Package.Scripts.Names(), spec.name, excludes) {
+				if _, ok := w.Package.Scripts.Lookup(script); !ok {
+					continue
+				}
 
-			// See if any "scripts" match our prefix
-			matchingScripts := []string{}
+				runnable, err := scriptCommand(w.Package, w.Dir, script, spec.args)
+				if err != nil {
+					return err
+				}
 
-			for _, script := range scripts {
-				if strings.HasPrefix(script, prefix) {
-					matchingScripts = append(matchingScripts, script)
+				if !list.addScript(w.Name, runnable, w.Dir) {
+					continue
 				}
+
+				pkgNames = append(pkgNames, w.Name)
+				scriptNames = append(scriptNames, script)
 			}
+		}
+	}
+
+	deps := konk.WorkspaceDependencies(ws)
 
-			for _, script := range matchingScripts {
-				providedCommands = append(providedCommands, script)
+	counts := map[string]int{}
+	for _, pkg := range pkgNames {
+		counts[pkg]++
+	}
+
+	for i, pkg := range pkgNames {
+		if counts[pkg] > 1 {
+			list.provided[first+i] = pkg + ":" + scriptNames[i]
+		}
 
-				if runWithBun {
-					runnableCommands = append(runnableCommands, "bun run "+script)
-				} else {
-					runnableCommands = append(runnableCommands, "npm run "+script)
+		if topological {
+			for j, dep := range pkgNames {
+				if slices.Contains(deps[pkg], dep) {
+					list.dependsOn[first+i] = append(list.dependsOn[first+i], first+j)
 				}
 			}
+		}
+	}
 
-			continue
+	return nil
+}
+
+// matchScripts returns the scripts named by name: those matching it if it is a
+// glob, other than those matching any of excludes, or else name itself.
+func matchScripts(scripts []string, name string, excludes []*regexp.Regexp) []string {
+	if !strings.ContainsAny(name, "*?") {
+		return []string{name}
+	}
+
+	pattern := scriptPattern(name)
+	matches := []string{}
+
+	// Run every matching script, in the order they are declared
+	for _, script := range scripts {
+		excluded := slices.ContainsFunc(excludes, func(re *regexp.Regexp) bool { return re.MatchString(script) })
+
+		if pattern.MatchString(script) && !excluded {
+			matches = append(matches, script)
 		}
+	}
 
-		providedCommands = append(providedCommands, cmd)
+	return matches
+}
 
-		if runWithBun {
-			runnableCommands = append(runnableCommands, "bun run "+cmd)
-		} else {
-			runnableCommands = append(runnableCommands, "npm run "+cmd)
+// scriptPattern compiles an npm-run-all-style glob over script names, whose
+// segments are separated by ":". "*" matches within a single segment, "**"
+// matches across any number of segments, and "?" matches one character.
+func scriptPattern(glob string) *regexp.Regexp {
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^:]*")
+		case glob[i] == '?':
+			b.WriteString("[^:]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
 		}
 	}
 
-	return providedCommands, runnableCommands, nil
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
 }
 
-func getPackageJSONScripts() ([]string, error) {
-	pkgFile, err := os.ReadFile("package.json")
-	if err != nil {
-		return nil, fmt.Errorf("reading package.json: %w", err)
+// resolvePackageManager returns the package manager that runs -n scripts in
+// dir: the one given with --package-manager or -b, or else the one detected
+// from dir's lockfiles and package.json.
+func resolvePackageManager(dir string) (konk.PackageManager, error) {
+	if packageManager != "" {
+		pm, err := konk.ParsePackageManager(packageManager)
+		if err != nil {
+			return "", err
+		}
+
+		if runWithBun && pm != konk.Bun {
+			return "", fmt.Errorf("--bun conflicts with --package-manager %s", pm)
+		}
+
+		return pm, nil
 	}
 
-	var pkgJSON struct {
-		Scripts map[string]string `json:"scripts"`
+	if runWithBun {
+		return konk.Bun, nil
 	}
 
-	if err := json.Unmarshal(pkgFile, &pkgJSON); err != nil {
-		return nil, fmt.Errorf("unmarshalling package.json: %w", err)
+	if dir == "" {
+		dir = "."
 	}
 
-	scripts := make([]string, 0, len(pkgJSON.Scripts))
-	for script := range pkgJSON.Scripts {
-		scripts = append(scripts, script)
+	return konk.DetectPackageManager(dir)
+}
+
+// scriptCommand returns the command that runs a script of the package in dir,
+// either directly with --direct or else with the package's package manager.
+func scriptCommand(pkgJSON *konk.PackageJSON, dir, script, args string) (string, error) {
+	if direct {
+		return pkgJSON.DirectCommand(dir, script, args)
 	}
 
-	sort.Strings(scripts)
+	pm, err := resolvePackageManager(dir)
+	if err != nil {
+		return "", err
+	}
 
-	return scripts, nil
+	return pm.RunScript(script, args), nil
 }
 
 func collectLabels(commandStrings []string) []string {
@@ -140,22 +608,95 @@ func collectLabels(commandStrings []string) []string {
 			labels[i] = cmdStr
 		case len(names) > 0:
 			labels[i] = names[i]
+		case workspaces:
+			labels[i] = cmdStr
 		default:
 			labels[i] = strconv.Itoa(i)
 		}
 	}
 
-	var maxLabelLen int
+	return alignLabels(labels)
+}
 
-	for _, label := range labels {
-		if len(label) > maxLabelLen {
-			maxLabelLen = len(label)
+// labelTargets returns the names each command can be addressed by in flags
+// such as --env: its label, if it has one, and its index.
+func labelTargets(labels []string) [][]string {
+	targets := make([][]string, len(labels))
+
+	for i, label := range labels {
+		targets[i] = []string{strconv.Itoa(i)}
+
+		if label := strings.TrimSpace(label); label != "" {
+			targets[i] = append(targets[i], label)
 		}
 	}
 
+	return targets
+}
+
+// cutTarget splits a value of the form <target>:VALUE, as given to flags such
+// as --env, --shell, and --cwd. Targets such as script names often contain
+// ":", while values rarely do, so the last ":" separates the target. A value
+// without one applies to every command.
+func cutTarget(value string) (string, string, bool) {
+	i := strings.LastIndex(value, ":")
+	if i < 0 {
+		return "", value, false
+	}
+
+	return value[:i], value[i+1:], true
+}
+
+// matchTarget returns the indexes of the commands that target addresses by
+// any of their targets, such as a label. It's an error for the value flag
+// given as name to address none.
+func matchTarget(targets [][]string, target, name, flag string) ([]int, error) {
+	var matched []int
+
+	for i, t := range targets {
+		if slices.Contains(t, target) {
+			matched = append(matched, i)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("invalid %s %q: no command %q", name, flag, target)
+	}
+
+	return matched, nil
+}
+
+// alignLabels truncates labels to --max-label-width and pads them to a common
+// width. Widths are measured in terminal columns rather than bytes, so that
+// labels containing wide or multi-byte characters still line up.
+func alignLabels(labels []string) []string {
+	aligned := make([]string, len(labels))
+
+	var labelWidth int
+
 	for i, label := range labels {
-		labels[i] = fmt.Sprintf("%s%s", label, strings.Repeat(" ", maxLabelLen-len(label)))
+		if maxLabelWidth > 0 && runewidth.StringWidth(label) > maxLabelWidth {
+			label = runewidth.Truncate(label, maxLabelWidth, "…")
+		}
+
+		aligned[i] = label
+		labelWidth = max(labelWidth, runewidth.StringWidth(label))
+	}
+
+	for i, label := range aligned {
+		aligned[i] = runewidth.FillRight(label, labelWidth)
+	}
+
+	return aligned
+}
+
+// splitForwardedArgs splits a command's arguments at "--", into the commands
+// to run and the arguments to forward to -n scripts.
+func splitForwardedArgs(cmd *cobra.Command, args []string) ([]string, []string) {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 {
+		return args, nil
 	}
 
-	return labels
+	return args[:dash], args[dash:]
 }
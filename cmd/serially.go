@@ -1,18 +1,16 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"os"
 
 	"github.com/jclem/konk/konk"
-	"github.com/mattn/go-shellwords"
 	"github.com/spf13/cobra"
 )
 
 var sCommand = cobra.Command{
-	Use:     "serially <command...>",
+	Use:     "serially <command...> [-- <script args...>]",
 	Aliases: []string{"s"},
 	Short:   "Run commands serially (alias: s)",
 	Example: `# Run two commands in serial
@@ -21,7 +19,21 @@ konk run serially "echo foo" "echo bar"
 
 # Run a set of npm commands in serial
 
-konk run serially -n build -n deploy`,
+konk run serially -n build -n deploy
+
+# Run a set of npm commands in serial with variables from .env.production
+
+konk run serially --mode production -n build -n deploy
+
+# Print the commands, directories, and variables a run would use, without
+# running anything
+
+konk run serially --dry-run --mode production -n build -n deploy
+
+# Run the test script with --watch, and the lint script with the first
+# argument only
+
+konk run serially -n test -n "lint {1}" -- --watch`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
@@ -35,69 +47,57 @@ konk run serially -n build -n deploy`,
 			}
 		}
 
-		commandStrings, cmdParts, err := collectCommands(args)
+		args, forwarded := splitForwardedArgs(cmd, args)
+
+		list, err := collectCommands(args, forwarded, flagSelection())
 		if err != nil {
 			return err
 		}
 
-		if len(names) > 0 && len(names) != len(cmdParts) {
+		if len(names) > 0 && len(names) != len(list.runnable) {
 			return errors.New("number of names must match number of commands")
 		}
 
-		labels := collectLabels(commandStrings)
-
-		var errCmd error
-
-		commands := make([]*konk.Command, len(cmdParts))
-
-		for i, cmd := range cmdParts {
-			var c *konk.Command
-
-			if noShell {
-				parts, err := shellwords.Parse(cmd)
-
-				if err != nil {
-					return fmt.Errorf("parsing command: %w", err)
-				}
-
-				c = konk.NewCommand(konk.CommandConfig{
-					Name:    parts[0],
-					Args:    parts[1:],
-					Label:   labels[i],
-					NoColor: noColor,
-					Env:     make([]string, 0),
-					OmitEnv: false,
-				})
-			} else {
-				c = konk.NewShellCommand(konk.ShellCommandConfig{
-					Command: cmd,
-					Label:   labels[i],
-					NoColor: noColor,
-					Env:     make([]string, 0),
-					OmitEnv: false,
-				})
-			}
+		labels := collectLabels(list.provided)
+		targets := labelTargets(labels)
 
-			commands[i] = c
+		env, commandEnv, err := resolveEnv(targets, false)
+		if err != nil {
+			return err
 		}
 
+		commandShells, err := resolveShells(targets)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			plan := newPlanStage("serially", list, labels, commandEnv, commandShells)
+			return printPlan(cmd.OutOrStdout(), []planStage{plan}, false)
+		}
+
+		commands, err := konk.RunSerially(ctx, konk.RunSeriallyConfig{
+			Commands:        list.runnable,
+			Labels:          labels,
+			EnvVars:         env,
+			CommandEnv:      commandEnv,
+			Dirs:            list.dirs,
+			OmitEnv:         omitEnv,
+			ContinueOnError: continueOnError,
+			NoColor:         noColor,
+			NoShell:         noShell,
+			Expand:          expand,
+			Shells:          commandShells,
+			ShellOpts:       shellOpts,
+		})
+
 		debugCommands(ctx, commands)
 
-		for _, c := range commands {
-			ctx, cancel := context.WithCancel(context.Background())
-			defer cancel()
-
-			if err := c.Run(ctx, cancel, konk.RunCommandConfig{
-				AggregateOutput: false,
-				StopOnCancel:    false,
-			}); err != nil && continueOnError {
-				errCmd = err
-			} else if err != nil {
-				return fmt.Errorf("running command: %w", err)
-			}
+		if err != nil {
+			return fmt.Errorf("running commands: %w", err)
 		}
 
-		return errCmd
+		return nil
 	},
 }
 
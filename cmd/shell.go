@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+var shells []string
+var shellOpts string
+var expand bool
+
+// addShellFlags adds the flags that choose the shell commands run in, or how
+// commands are split into words without one.
+func addShellFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&shells, "shell", []string{},
+		"shell to run every command in, e.g. \"bash\", or <label>:SHELL for a single command (default /bin/sh)")
+	flags.StringVar(&shellOpts, "shell-opts", "",
+		"shell code to run before each command, e.g. \"set -euo pipefail\"")
+	flags.BoolVar(&expand, "expand", false,
+		"with --no-subshell, expand variables, a leading ~, and globs in commands")
+}
+
+// resolveShells applies --shell, returning the shell for each command, or nil
+// if every command runs in the default shell. Each command may be addressed by
+// any of its targets, as with --env. It also checks that --shell and
+// --shell-opts aren't combined with --no-subshell or --direct, and that
+// --expand is only given with --no-subshell.
+func resolveShells(targets [][]string) ([]string, error) {
+	if noShell && (len(shells) > 0 || shellOpts != "") {
+		return nil, errors.New("--shell and --shell-opts can't be combined with --no-subshell")
+	}
+
+	// Scripts run with --direct run their steps with /bin/sh, as npm does.
+	if direct && (len(shells) > 0 || shellOpts != "") {
+		return nil, errors.New("--shell and --shell-opts can't be combined with --direct")
+	}
+
+	if expand && !noShell {
+		return nil, errors.New("--expand requires --no-subshell")
+	}
+
+	if len(shells) == 0 {
+		return nil, nil
+	}
+
+	resolved := make([]string, len(targets))
+
+	// A shell for every command is applied first, so that one for a single
+	// command overrides it regardless of the order they're given in.
+	for _, flag := range shells {
+		if _, _, ok := cutTarget(flag); !ok {
+			for i := range resolved {
+				resolved[i] = flag
+			}
+		}
+	}
+
+	for _, flag := range shells {
+		target, shell, ok := cutTarget(flag)
+		if !ok {
+			continue
+		}
+
+		if shell == "" {
+			return nil, fmt.Errorf("invalid --shell %q: missing shell", flag)
+		}
+
+		matched, err := matchTarget(targets, target, "--shell", flag)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range matched {
+			resolved[i] = shell
+		}
+	}
+
+	return resolved, nil
+}
@@ -11,6 +11,7 @@ type runner struct {
 	cmd   string
 	flags []string
 	env   []string
+	stdin string
 }
 
 func newRunner(cmd string) runner {
@@ -18,6 +19,7 @@ func newRunner(cmd string) runner {
 		cmd:   cmd,
 		flags: make([]string, 0),
 		env:   make([]string, 0),
+		stdin: "",
 	}
 }
 
@@ -31,6 +33,11 @@ func (r runner) withEnv(env ...string) runner {
 	return r
 }
 
+func (r runner) withStdin(stdin string) runner {
+	r.stdin = stdin
+	return r
+}
+
 func (r runner) run(t *testing.T) (string, error) {
 	t.Helper()
 
@@ -40,6 +47,7 @@ func (r runner) run(t *testing.T) (string, error) {
 	cmd.Stdout = out
 	cmd.Stderr = out
 	cmd.Env = append(cmd.Env, r.env...)
+	cmd.Stdin = strings.NewReader(r.stdin)
 
 	err := cmd.Run()
 	return out.String(), err
@@ -0,0 +1,129 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteScripts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("run", "serially", "-w", "fixtures/glob", "-n", "lint:c").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `lint:css
+lint:css:fix
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteScriptsExclude(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("run", "concurrently", "-w", "fixtures/glob", "-n", "lint:*", "-n", "!lint:js").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `!lint:js
+!lint:js:fix
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteWorkspaceScripts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("run", "-w", "fixtures/workspaces", "--workspaces", "-n", "").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `build
+serve
+test
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteTasks(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("run", "serially", "-w", "fixtures/tasks", "--just", "").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `test-unit
+test-e2e
+test-url
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteLabels(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("run", "serially", "-w", "fixtures/glob", "-n", "lint:*", "-n", "build", "-l", "js", "-l", "").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `lint:css
+build
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteLabelsEquals(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("run", "-w", "fixtures/glob", "-n", "lint:*", "--then", "--npm=build", "-l", "js", "--label=").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `lint:css
+build
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteProcesses(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("proc", "-w", "fixtures/proc", "echo-b", "").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `echo-a
+echo-c
+:4
+Completion ended with directive: ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
+
+func TestCompleteProcessDirs(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("__complete").
+		withFlags("proc", "-w", "fixtures/proc", "--cwd", "echo-a").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `echo-a:
+:6
+Completion ended with directive: ShellCompDirectiveNoSpace, ShellCompDirectiveNoFileComp
+`, out, "output did not match expected output")
+}
@@ -0,0 +1,176 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnv(t *testing.T) {
+	t.Parallel()
+
+	out, err := newEnvRunner().run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `A=base   # .env:1
+B=local  # .env.local:1
+C=base   # .env:3
+`, out, "output did not match expected output")
+}
+
+func TestEnvMode(t *testing.T) {
+	t.Parallel()
+
+	out, err := newEnvRunner().withFlags("--mode", "test").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `A=base         # .env:1
+B=local        # .env.local:1
+C="test base"  # .env.test:1
+D=test         # .env.test:2
+`, out, "output did not match expected output")
+}
+
+func TestEnvFiles(t *testing.T) {
+	t.Parallel()
+
+	out, err := newEnvRunner().withFlags("-e", ".env.test", "-e", ".env.local").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `C="test "  # .env.test:1
+D=test     # .env.test:2
+B=local    # .env.local:1
+`, out, "output did not match expected output")
+}
+
+func TestProcLayeredEnv(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("proc").withFlags("-w", "fixtures/env", "--mode", "test").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[echo] base local test base test
+`, out, "output did not match expected output")
+}
+
+func TestEnvSyntax(t *testing.T) {
+	t.Parallel()
+
+	out, err := newEnvRunner().withFlags("-e", ".env-syntax").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `EXPORTED=yes                                                # .env-syntax:3
+UNQUOTED="plain value"                                      # .env-syntax:4
+SINGLE="\$EXPORTED stays"                                   # .env-syntax:5
+BACKTICK="it's \"quoted\""                                  # .env-syntax:6
+MULTILINE="first\nsecond"                                   # .env-syntax:7
+ESCAPES="tab\tnewline\nquote\"backslash\\dollar\$EXPORTED"  # .env-syntax:9
+EMPTY=                                                      # .env-syntax:10
+REF=yes-yes                                                 # .env-syntax:11
+COLON_DEFAULT=fallback                                      # .env-syntax:12
+DASH_DEFAULT=                                               # .env-syntax:13
+UNSET_DEFAULT=fallback                                      # .env-syntax:14
+`, out, "output did not match expected output")
+}
+
+func TestEnvUnsupportedExpansion(t *testing.T) {
+	t.Parallel()
+
+	for file, ref := range map[string]string{
+		".env-unsupported":        "${OK:+set}",
+		".env-unsupported-quoted": "${#OK}",
+	} {
+		out, err := newEnvRunner().withFlags("-e", file).run(t)
+		require.Error(t, err)
+
+		assert.Contains(t, out, "parsing env file "+file+": line 2: unsupported expansion "+ref)
+	}
+}
+
+func TestEnvCRLF(t *testing.T) {
+	t.Parallel()
+
+	out, err := newEnvRunner().withFlags("-e", ".env-crlf").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `CRLF=windows        # .env-crlf:1
+CRLF_QUOTED=quoted  # .env-crlf:2
+CRLF_NEXT=next      # .env-crlf:3
+`, out, "output did not match expected output")
+}
+
+func newEnvRunner() runner {
+	return newRunner("env").withFlags("-w", "fixtures/env")
+}
+
+func TestRunEnv(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/env", "--mode", "test", "-l", "a", "-l", "b",
+			"--env", "D=inline", "--env", "b:D=override", "--env", "0:E=first",
+			"echo $A $D $E", "echo $A $D $E").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[a] base inline first
+[b] base override
+`, out, "output did not match expected output")
+}
+
+func TestRunEnvNotLoadedByDefault(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/env", `echo "${A:-unset}"`).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[0] unset\n", out, "output did not match expected output")
+}
+
+func TestRunEnvUnknownTarget(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("concurrently", "-w", "fixtures/env", "--env", "c:D=x", "echo a", "echo b").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, `invalid --env "c:D=x": no command "c"`)
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("exec").
+		withFlags("-w", "fixtures/env", "--mode", "test", "--", "sh", "-c", "echo $A $C $PORT").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "base test base 5000\n", out, "output did not match expected output")
+}
+
+func TestExecOverridesParentEnv(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("exec").
+		withFlags("-w", "fixtures/env", "--port", "3000", "printenv", "A", "PORT").
+		withEnv("A=parent", "PORT=4000", "PATH=/usr/bin:/bin").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "base\n3000\n", out, "output did not match expected output")
+}
+
+func TestExecPort(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("exec").
+		withFlags("-w", "fixtures/env", "--port", "3000", "--env", "A=inline", "sh", "-c", "echo $A $PORT $KONK_PORT").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "inline 3000 3000\n", out, "output did not match expected output")
+}
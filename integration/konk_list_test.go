@@ -0,0 +1,82 @@
+package integration_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("list").withFlags("-w", "fixtures/list").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `package.json
+  check:types  tsc --noEmit
+  check:lint   eslint .
+  build        vite build
+
+Procfile
+  web  vite dev
+
+Makefile
+  check-go
+`, out, "output did not match expected output")
+}
+
+func TestListPattern(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("ls").withFlags("-w", "fixtures/list", "check:*", "check-*", "--json").run(t)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `[
+  {
+    "source": "package.json",
+    "tasks": [
+      {"name": "check:types", "command": "tsc --noEmit"},
+      {"name": "check:lint", "command": "eslint ."}
+    ]
+  },
+  {
+    "source": "Makefile",
+    "tasks": [{"name": "check-go"}]
+  }
+]`, out, "output did not match expected output")
+}
+
+func TestListTasks(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("list").withFlags("-w", "fixtures/tasks", "--json").run(t)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `[
+		{"source": "Makefile", "tasks": [
+			{"name": "build-go"}, {"name": "build-docs"}, {"name": "test"}, {"name": "release"}, {"name": "package"}
+		]},
+		{"source": "justfile", "tasks": [{"name": "test-unit"}, {"name": "test-e2e"}, {"name": "test-url"}]},
+		{"source": "Taskfile", "tasks": [{"name": "lint:go"}, {"name": "lint:js"}, {"name": "build"}]},
+		{"source": "deno.json", "tasks": [
+			{"name": "dev:server", "command": "deno run server.ts"},
+			{"name": "dev:client", "command": "deno run client.ts"},
+			{"name": "build", "command": "deno compile server.ts"},
+			{"name": "ci"}
+		]}
+	]`, out, "output did not match expected output")
+}
+
+func TestListBrokenSource(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("list").withFlags("-w", "fixtures/list-broken").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `package.json
+  build  vite build
+`, out[strings.Index(out, "package.json"):], "output did not match expected output")
+	assert.Contains(t, out, `skipping deno.json: unmarshalling deno.json: reading script "build": task must be a string or an object`)
+}
@@ -0,0 +1,171 @@
+package integration_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var statusPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^NAME +STATE +PID +RESTARTS$`),
+	regexp.MustCompile(`(?m)^sleep +running +\d+ +0$`),
+	regexp.MustCompile(`(?m)^done +exited +- +0$`),
+}
+
+func TestCtl(t *testing.T) {
+	t.Parallel()
+
+	session := startCtlSession(t, "fixtures/ctl")
+
+	require.Eventually(t, func() bool {
+		out, err := newCtlRunner("fixtures/ctl").withFlags("status").run(t)
+		require.NoError(t, err)
+		for _, p := range statusPatterns {
+			if !p.MatchString(out) {
+				return false
+			}
+		}
+
+		return true
+	}, 5*time.Second, 10*time.Millisecond, "status did not report the running session")
+
+	_, err := newCtlRunner("fixtures/ctl").withFlags("stop", "sleep").run(t)
+	require.NoError(t, err)
+
+	// Stopping the last running command ends the session cleanly.
+	require.NoError(t, session.Wait())
+
+	_, err = os.Stat("fixtures/ctl/.konk.sock")
+	assert.ErrorIs(t, err, os.ErrNotExist, "control socket was not removed")
+}
+
+func TestCtlRestart(t *testing.T) {
+	t.Parallel()
+
+	session := startCtlSession(t, "fixtures/ctl-restart")
+	ctl := newCtlRunner("fixtures/ctl-restart")
+
+	running := regexp.MustCompile(`(?m)^sleep +running +(\d+) +0$`)
+
+	var pid string
+
+	require.Eventually(t, func() bool {
+		out, err := ctl.withFlags("status").run(t)
+		require.NoError(t, err)
+
+		match := running.FindStringSubmatch(out)
+		if match == nil || !regexp.MustCompile(`(?m)^done +exited`).MatchString(out) {
+			return false
+		}
+
+		pid = match[1]
+
+		return true
+	}, 5*time.Second, 10*time.Millisecond, "status did not report the running session")
+
+	_, err := ctl.withFlags("restart", "sleep").run(t)
+	require.NoError(t, err)
+
+	restarted := regexp.MustCompile(`(?m)^sleep +running +(\d+) +1$`)
+
+	require.Eventually(t, func() bool {
+		out, err := ctl.withFlags("status").run(t)
+		require.NoError(t, err)
+
+		match := restarted.FindStringSubmatch(out)
+
+		return match != nil && match[1] != pid
+	}, 5*time.Second, 10*time.Millisecond, "sleep was not restarted with a new PID")
+
+	// An exited command is started again.
+	_, err = ctl.withFlags("restart", "done").run(t)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		out, err := ctl.withFlags("status").run(t)
+		require.NoError(t, err)
+
+		return regexp.MustCompile(`(?m)^done +exited +- +1$`).MatchString(out)
+	}, 5*time.Second, 10*time.Millisecond, "done was not started again")
+
+	out, err := ctl.withFlags("logs", "done").run(t)
+	require.NoError(t, err)
+	assert.Equal(t, "done\ndone\n", out, "output did not match expected output")
+
+	_, err = ctl.withFlags("stop", "sleep").run(t)
+	require.NoError(t, err)
+	require.NoError(t, session.Wait())
+}
+
+func TestCtlLogs(t *testing.T) {
+	t.Parallel()
+
+	session := startCtlSession(t, "fixtures/ctl-logs")
+	ctl := newCtlRunner("fixtures/ctl-logs")
+
+	require.Eventually(t, func() bool {
+		out, err := ctl.withFlags("logs", "tick").run(t)
+		require.NoError(t, err)
+
+		return out == "one\n"
+	}, 5*time.Second, 10*time.Millisecond, "logs did not report the first line")
+
+	var followed bytes.Buffer
+
+	follow := exec.Command("bin/konk", "ctl", "-w", "fixtures/ctl-logs", "logs", "tick", "--follow")
+	follow.Stdout = &followed
+	require.NoError(t, follow.Start())
+
+	require.Eventually(t, func() bool {
+		out, err := ctl.withFlags("logs", "tick").run(t)
+		require.NoError(t, err)
+
+		return out == "one\ntwo\n"
+	}, 5*time.Second, 10*time.Millisecond, "logs did not report the second line")
+
+	// Following ends with the session.
+	_, err := ctl.withFlags("stop", "tick").run(t)
+	require.NoError(t, err)
+	require.NoError(t, session.Wait())
+	require.NoError(t, follow.Wait())
+
+	assert.Equal(t, "one\ntwo\n", followed.String(), "output did not match expected output")
+}
+
+func TestCtlNoSession(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("ctl").withFlags("status", "-w", "fixtures/npm").run(t)
+
+	assert.IsType(t, &exec.ExitError{}, err) //nolint:exhaustruct
+
+	assert.Equal(t,
+		"Error: no control socket at .konk.sock; start konk with --control\n", out,
+		"error output did not match expectation")
+}
+
+// startCtlSession starts "konk proc" in dir with a control socket, and waits
+// for the socket to be created.
+func startCtlSession(t *testing.T, dir string) *exec.Cmd {
+	t.Helper()
+
+	session := exec.Command("bin/konk", "proc", "-E", "--control", "-w", dir)
+	require.NoError(t, session.Start())
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(dir + "/.konk.sock")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "control socket was not created")
+
+	return session
+}
+
+func newCtlRunner(dir string) runner {
+	return newRunner("ctl").withFlags("-w", dir)
+}
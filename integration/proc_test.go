@@ -1,6 +1,7 @@
 package integration_test
 
 import (
+	"os/exec"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,6 +34,33 @@ func TestProcEnvSpaces(t *testing.T) {
 `, sortOut(t, out), "output did not match expected output")
 }
 
+func TestProcDotenv(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags(
+		"-e", ".env-dotenv",
+		"-p", "Procfile-dotenv").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[message] hello, wor
+[message] ld
+[message] $GREETING
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcEnvInvalid(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-e", ".env-invalid").run(t)
+
+	assert.IsType(t, &exec.ExitError{}, err) //nolint:exhaustruct
+
+	assert.Equal(t,
+		"Error: parsing env file .env-invalid: line 2: unterminated \"-quoted value\n", out,
+		"error output did not match expectation")
+}
+
 func TestProcWithExternalEnvNoEnv(t *testing.T) {
 	t.Parallel()
 
@@ -75,6 +103,179 @@ b
 `, sortOut(t, out), "output did not match expected output")
 }
 
+func TestProcComments(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-comments").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[echo-a] a
+[echo-b] b
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcInvalid(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-invalid").run(t)
+
+	assert.IsType(t, &exec.ExitError{}, err) //nolint:exhaustruct
+
+	assert.Equal(t,
+		"Error: Procfile-invalid: line 2: expected \"<name>: <command>\"\n", out,
+		"error output did not match expectation")
+}
+
+func TestProcFormation(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-formation", "-m", "ps=2,other=0").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[ps.1] ps.1 1
+[ps.2] ps.2 2
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcFormationAll(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-formation", "-m", "all=2,ps=1").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[other.1] other
+[other.2] other
+[ps.1   ] ps.1 1
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcFormationUnknown(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-formation", "-m", "web=2").run(t)
+
+	assert.IsType(t, &exec.ExitError{}, err) //nolint:exhaustruct
+
+	assert.Equal(t,
+		"Error: invalid formation: unknown process type \"web\"\n", out,
+		"error output did not match expectation")
+}
+
+func TestProcPort(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-port", "-m", "web=2", "--port", "3000").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[web.1   ] 3000 3000
+[web.2   ] 3001 3001
+[worker.1] 3100
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcPortFromEnv(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-port").withEnv("PORT=4000").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[web   ] 4000 4000
+[worker] 4100
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcSubset(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("echo-a", "echo-c").withEnv("C=c").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[echo-a] a
+[echo-c] c
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcExcept(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("-p", "Procfile-port", "--except", "web").run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[worker] 5100
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcSubsetUnknown(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags("echo-z").run(t)
+
+	assert.IsType(t, &exec.ExitError{}, err) //nolint:exhaustruct
+
+	assert.Equal(t,
+		"Error: unknown process type \"echo-z\"\n", out,
+		"error output did not match expectation")
+}
+
+func TestProcCwd(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("proc").
+		withFlags("-w", "fixtures/cwd", "--cwd", "api:api", "--cwd", "web:web").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[api] api
+[web] web
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestProcCwdUnknown(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("proc").withFlags("-w", "fixtures/cwd", "--cwd", "worker:api").run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, `invalid --cwd "worker:api": no command "worker"`)
+}
+
+func TestProcCwdMissingType(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("proc").withFlags("-w", "fixtures/cwd", "--cwd", "api").run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, `invalid --cwd "api": expected <type>:DIR`)
+}
+
+func TestProcDryRun(t *testing.T) {
+	t.Parallel()
+
+	out, err := newProcRunner().withFlags(
+		"--dry-run", "-E",
+		"-p", "Procfile-formation",
+		"-m", "ps=2,other=0",
+		"--port", "3000",
+		"--cwd", "ps:/tmp").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `Run concurrently:
+  [ps.1] echo $PS $KONK_PROCESS_INDEX
+      in /tmp
+      with PS=ps.1
+      with KONK_PROCESS_INDEX=1
+      with PORT=3000
+      with KONK_PORT=3000
+  [ps.2] echo $PS $KONK_PROCESS_INDEX
+      in /tmp
+      with PS=ps.2
+      with KONK_PROCESS_INDEX=2
+      with PORT=3001
+      with KONK_PORT=3001
+`, out, "output did not match expected output")
+}
+
 func newProcRunner() runner {
 	return newRunner("proc").withFlags("-w", "fixtures/proc")
 }
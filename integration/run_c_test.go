@@ -131,6 +131,59 @@ c
 `, sortOut(t, out), "output did not match expected output")
 }
 
+func TestRunConcurrentlyDeclaredOrder(t *testing.T) {
+	t.Parallel()
+
+	out, err := newGroupedConcurrentRunner().
+		withFlags("--order", "declared", "sleep 0.2; echo a", "echo b").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] a
+[1] b
+`, out, "output did not match expected output")
+}
+
+func TestRunConcurrentlyDeclaredOrderStartFailure(t *testing.T) {
+	t.Parallel()
+
+	out, err := newGroupedConcurrentRunner().
+		withFlags("--order", "declared", "-c", "-S", "konk-no-such-command", "echo b").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "[1] b\n", "output after the failed command was not printed")
+}
+
+func TestRunConcurrentlyGroupHeader(t *testing.T) {
+	t.Parallel()
+
+	out, err := newGroupedConcurrentRunner().
+		withFlags("--group-header", "-l", "a", "echo a").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^=== a ===
+\[a\] a
+=== a finished in \d+(\.\d+)?m?s ===
+$`, out, "output did not match expected output")
+}
+
+func TestRunConcurrentlyInteractive(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("concurrently", "-i", "-l", "sleeper", "sleep 30").
+		withStdin("bogus\nstop sleeper\n").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^konk: unknown command "bogus" \(type "help" for a list of commands\)
+NAME +STATE +PID +RESTARTS
+sleeper +(pending|running) +(-|\d+) +0
+$`, out, "output did not match expected output")
+}
+
 func newGroupedConcurrentRunner() runner {
 	return newRunner("run").withFlags("concurrently", "-g")
 }
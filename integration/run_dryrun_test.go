@@ -0,0 +1,87 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDryRun(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags(
+			"concurrently", "--dry-run", "-w", "fixtures/env", "--mode", "test",
+			"--env", "X=1", "-l", "a", "-l", "b", "--env", "b:Y=2",
+			"echo a > ran", "echo b > ran",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `Env files:
+  A=***  # .env:1
+  B=***  # .env.local:1
+  C=***  # .env.test:1
+  D=***  # .env.test:2
+Env:
+  X=1
+Run concurrently:
+  [a] echo a > ran
+  [b] echo b > ran
+      with Y=2
+`, out, "output did not match expected output")
+	assert.NoFileExists(t, "fixtures/env/ran")
+}
+
+func TestRunDryRunScripts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("--dry-run", "-w", "fixtures/cwd", "-L", "--cwd", "web", "pwd", "--cwd", "api", "-n", "hello-*").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `Run serially:
+  [pwd] pwd
+      in web
+  [hello-api] npm run hello-api
+      in api
+`, out, "output did not match expected output")
+}
+
+func TestRunDryRunTopological(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("concurrently", "--dry-run", "-w", "fixtures/workspaces", "--workspaces", "--topological", "-n", "build").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `Run concurrently:
+  [util] npm run build
+      in packages/util
+  [lib] npm run build
+      in packages/lib
+      after util
+  [app] npm run build
+      in packages/app
+      after util, lib
+`, out, "output did not match expected output")
+}
+
+func TestRunDryRunPipeline(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("--dry-run", "echo a", "--then", "--parallel", "echo b", "echo c").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `Stage 1, run serially:
+  [0] echo a
+Stage 2, run concurrently:
+  [1] echo b
+  [2] echo c
+`, out, "output did not match expected output")
+}
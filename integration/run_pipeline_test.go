@@ -0,0 +1,91 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPipeline(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags(
+			"echo a",
+			"--then", "--parallel", "sleep 0.2 && echo b", "echo c",
+			"--then", "echo d",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] a
+[2] c
+[1] b
+[3] d
+`, out, "output did not match expected output")
+}
+
+func TestRunPipelineScripts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags(
+			"-w", "fixtures/glob", "--direct", "-L",
+			"-n", "build", "--parallel", "-n", "lint:*",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[build   ] build
+[lint:css] css
+[lint:js ] js
+`, sortOut(t, out), "output did not match expected output")
+}
+
+func TestRunPipelineFailure(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("echo a", "exit 1", "--then", "echo never").
+		run(t)
+	require.Error(t, err)
+
+	assert.NotContains(t, out, "never")
+}
+
+func TestRunPipelineContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("-c", "exit 1", "--then", "echo after").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "[1] after")
+}
+
+func TestRunPipelineStageFlagFalse(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("--parallel", "sleep 0.2 && echo a", "--then=false", "echo b").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[1] b
+[0] a
+`, out, "output did not match expected output")
+}
+
+func TestRunPipelineEmptyStage(t *testing.T) {
+	t.Parallel()
+
+	out, err := newRunner("run").
+		withFlags("echo a", "--then").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "empty stage: --then and --parallel must be followed by a command")
+	assert.NotContains(t, out, "[0] a")
+}
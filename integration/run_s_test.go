@@ -2,6 +2,7 @@ package integration_test
 
 import (
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,6 +70,34 @@ func TestRunSeriallyWithCommandLabels(t *testing.T) {
 `, out, "output did not match expected output")
 }
 
+func TestRunSeriallyWithWideLabels(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-l", "日本", "-l", "a",
+			"echo a", "echo b").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[日本] a
+[a   ] b
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyWithMaxLabelWidth(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-L", "--max-label-width", "6",
+			"echo a", "echo hello").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[echo a] a
+[echo …] hello
+`, out, "output did not match expected output")
+}
+
 func TestRunSeriallyWithNpm(t *testing.T) {
 	t.Parallel()
 
@@ -118,6 +147,246 @@ func TestRunSeriallyWithNpmGlob(t *testing.T) {
 `, out, "output did not match expected output")
 }
 
+func TestRunSeriallyCwd(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags(
+			"-w", "fixtures/cwd",
+			"--cwd", "api", `basename "$(pwd)"`,
+			"--cwd", "web", `basename "$(pwd)"`,
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] api
+[1] web
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyCwdNpm(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags(
+			"-w", "fixtures/cwd",
+			"--cwd", "api", "--npm", "hello-*",
+			"--cwd", "web", "--npm", "hello-*",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] 
+[0] > hello-api
+[0] > echo api
+[0] 
+[0] api
+[1] 
+[1] > hello-web
+[1] > echo web
+[1] 
+[1] web
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyCwdTargets(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags(
+			"-w", "fixtures/cwd", "--direct", "-l", "a", "-l", "b", "-l", "script",
+			"--cwd", "hello-*:api", "-n", "hello-*",
+			"--cwd", "web", `basename "$(pwd)"`, `basename "$(pwd)"`,
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[a     ] web
+[b     ] cwd
+[script] api
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyCwdMismatch(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/cwd", "--cwd", "api", "--cwd", "web", "echo a").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "more --cwd directories than commands")
+}
+
+func TestRunSeriallyCwdUnknownTarget(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/cwd", "--cwd", "lint:js:api", "echo a").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, `invalid --cwd "lint:js:api": no command "lint:js"`)
+}
+
+func TestRunSeriallyDetectPackageManager(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withEnv(fakePackageManagerPath(t)).
+		withFlags(
+			"--cwd", "fixtures/pm/pnpm", "--npm", "hello",
+			"--cwd", "fixtures/pm/yarn/app", "--npm", "hello",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] pnpm run hello
+[1] yarn run hello
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyPackageManagerFlag(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withEnv(fakePackageManagerPath(t)).
+		withFlags("-w", "fixtures/pm/pnpm", "--package-manager", "bun", "--npm", "hello").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[0] bun run hello\n", out, "output did not match expected output")
+}
+
+func TestRunSeriallyNpmGlobSegments(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withEnv(fakePackageManagerPath(t)).
+		withFlags("-w", "fixtures/glob", "-b", "-n", "lint:*", "-n", "lint:*:fix").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] bun run lint:js
+[1] bun run lint:css
+[2] bun run lint:css:fix
+[3] bun run lint:js:fix
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyNpmGlobOverlap(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/glob", "--direct", "-L", "-n", "lint:*", "-n", "lint:js").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[lint:js ] js
+[lint:css] css
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyNpmGlobExclude(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withEnv(fakePackageManagerPath(t)).
+		withFlags("-w", "fixtures/glob", "-b", "-n", "**", "-n", "!**:fix").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] bun run lint:js
+[1] bun run lint:css
+[2] bun run build
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyNpmForwardArgs(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags(
+			"-w", "fixtures/args", "--env", "npm_config_loglevel=silent",
+			"-n", "count", "--", "--watch", "y z",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[0] 2 args: --watch y z\n", out, "output did not match expected output")
+}
+
+func TestRunSeriallyNpmPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags(
+			"-w", "fixtures/args", "--env", "npm_config_loglevel=silent",
+			"-n", "count {2}", "-n", "count {@}", "-n", "count {*}", "-n", "count {3}",
+			"--", "x", "y z",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] 1 args: y z
+[1] 2 args: x y z
+[2] 1 args: x y z
+[3] 0 args:
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyForwardArgsWithoutScripts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().withFlags("echo a", "--", "x").run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "arguments after -- are only passed to -n scripts")
+}
+
+func TestRunSeriallyDirect(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags(
+			"-w", "fixtures/direct/app", "--direct",
+			"-n", "build", "-n", "hello", "-n", "count {@}",
+			"--", "x", "y z",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] pre prebuild
+[0] build app@1.2.3 build x y z
+[0] post postbuild
+[1] hello from .bin
+[2] 2 args: x y z
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyDirectLifecycleScript(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/direct/app", "--direct", "-n", "script {@}", "--", "x").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] echo "$npm_lifecycle_script" x
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyDirectNoShell(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/direct/app", "--direct", "-S", "-n", "build").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "--direct can't be combined with --no-subshell")
+}
+
 func TestRunSeriallyNoLabel(t *testing.T) {
 	t.Parallel()
 
@@ -130,6 +399,121 @@ c
 `, out, "output did not match expected output")
 }
 
+func TestRunSeriallyShell(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("--shell", "/bin/bash", "-l", "a", "-l", "b", "--shell", "b:/bin/sh",
+			`[[ -n $BASH_VERSION ]] && echo bash`, `echo "${BASH_VERSION:-sh}"`).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[a] bash
+[b] sh
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyShellLabelWithColon(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-l", "lint:js", "-l", "b", "--shell", "lint:js:/bin/bash",
+			`echo "${BASH_VERSION:+bash}"`, `echo "${BASH_VERSION:-sh}"`).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[lint:js] bash
+[b      ] sh
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyShellDirect(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/direct/app", "--direct", "--shell-opts", "set -e", "-n", "build").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "--shell and --shell-opts can't be combined with --direct")
+}
+
+func TestRunSeriallyShellOpts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("--shell", "/bin/bash", "--shell-opts", "set -euo pipefail", "false | cat; echo never").
+		run(t)
+	require.Error(t, err)
+
+	assert.NotContains(t, out, "[0] never")
+}
+
+func TestRunSeriallyShellNoSubshell(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().withFlags("-S", "--shell", "/bin/bash", "echo a").run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "--shell and --shell-opts can't be combined with --no-subshell")
+}
+
+func TestRunSeriallyExpand(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/env", "-S", "--expand", "-e", ".env", "-e", ".env.local",
+			"--env", "HOME=/home/konk", "-l", "vars", "-l", "globs",
+			`echo $A "$B ${C}" '$D' ~/bin ${UNSET:-default} $UNSET`,
+			`echo ../cwd/*/package.json '*.json' nomatch*`).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[vars ] base local base $D /home/konk/bin default
+[globs] ../cwd/api/package.json ../cwd/web/package.json *.json nomatch*
+`, out, "output did not match expected output")
+}
+
+func TestRunSeriallyExpandUnsupported(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().withFlags("-S", "--expand", `echo ${HOME:=x}`).run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "unsupported expansion ${HOME:=x}")
+}
+
+func TestRunSeriallyExpandWithoutNoSubshell(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().withFlags("--expand", "echo $HOME").run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "--expand requires --no-subshell")
+}
+
+func TestRunSeriallyError(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().withFlags("exit 1").run(t)
+	require.Error(t, err)
+
+	assert.Equal(t, `[0]  exited with error: exit status 1
+Error: running commands: [0]  exited with error: exit status 1
+`, out, "output did not match expected output")
+}
+
+// fakePackageManagerPath returns a PATH under which pnpm, yarn, and bun print
+// the commands they are given.
+func fakePackageManagerPath(t *testing.T) string {
+	t.Helper()
+
+	bin, err := filepath.Abs("fixtures/pm/bin")
+	require.NoError(t, err)
+
+	return "PATH=" + bin + ":/usr/bin:/bin"
+}
+
 func newSerialRunner() runner {
 	return newRunner("run").withFlags("serially")
 }
@@ -0,0 +1,69 @@
+package integration_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMake(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/tasks", "-L", "--make", "build-*", "--make", "test").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[build-go  ] build go
+[build-docs] build go
+[build-docs] build docs
+[test      ] test
+`, out, "output did not match expected output")
+}
+
+func TestRunMakeArgs(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().
+		withFlags("-w", "fixtures/tasks", "--make", "build-go", "--", "x", "y z").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[0] build go x y z\n", out, "output did not match expected output")
+}
+
+func TestRunTaskSources(t *testing.T) {
+	t.Parallel()
+
+	bin, err := filepath.Abs("fixtures/tasks/bin")
+	require.NoError(t, err)
+
+	out, err := newSerialRunner().
+		withEnv("PATH="+bin+":/usr/bin:/bin").
+		withFlags(
+			"-w", "fixtures/tasks",
+			"--just", "test-*", "--task", "lint:*", "--deno", "dev:*", "--deno", "!dev:client",
+			"--", "x",
+		).
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[0] just test-unit x
+[1] just test-e2e x
+[2] just test-url x
+[3] task lint:go -- x
+[4] task lint:js -- x
+[5] deno task dev:server x
+`, out, "output did not match expected output")
+}
+
+func TestRunTaskSourceMissing(t *testing.T) {
+	t.Parallel()
+
+	out, err := newSerialRunner().withFlags("-w", "fixtures/npm", "--just", "test").run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, "no justfile in .")
+}
@@ -0,0 +1,93 @@
+package integration_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWorkspaces(t *testing.T) {
+	t.Parallel()
+
+	out, err := newWorkspacesRunner("serially", "fixtures/workspaces").
+		withFlags("-n", "build").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[app ] app built
+[lib ] lib built
+[util] util built
+`, out, "output did not match expected output")
+}
+
+func TestRunWorkspacesTopological(t *testing.T) {
+	t.Parallel()
+
+	out, err := newWorkspacesRunner("concurrently", "fixtures/workspaces").
+		withFlags("--topological", "-n", "build").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[util] util built
+[lib ] lib built
+[app ] app built
+`, out, "output did not match expected output")
+}
+
+func TestRunWorkspacesFailedDependencyDeclaredOrder(t *testing.T) {
+	t.Parallel()
+
+	out, err := newWorkspacesRunner("concurrently", "fixtures/workspaces-failing").
+		withFlags("-c", "-g", "--order", "declared", "--topological", "--direct", "-n", "build").
+		run(t)
+	require.Error(t, err)
+
+	assert.Equal(t, `[util]  exited with error: exit status 3
+[zed ] zed built
+Error: running commands: [util]  exited with error: exit status 3
+`, out, "output did not match expected output")
+}
+
+func TestRunWorkspacesMultipleScripts(t *testing.T) {
+	t.Parallel()
+
+	out, err := newWorkspacesRunner("serially", "fixtures/workspaces").
+		withFlags("--topological", "-n", "build", "-n", "test").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[util     ] util built
+[lib:build] lib built
+[lib:test ] lib tested
+[app      ] app built
+`, out, "output did not match expected output")
+}
+
+func TestRunWorkspacesPnpm(t *testing.T) {
+	t.Parallel()
+
+	out, err := newWorkspacesRunner("serially", "fixtures/workspaces-pnpm").
+		withFlags("-n", "build").
+		run(t)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[a] a built\n", out, "output did not match expected output")
+}
+
+func TestRunWorkspacesUnknownScript(t *testing.T) {
+	t.Parallel()
+
+	out, err := newWorkspacesRunner("serially", "fixtures/workspaces").
+		withFlags("-n", "deploy").
+		run(t)
+	require.Error(t, err)
+
+	assert.Contains(t, out, `no workspace defines script "deploy"`)
+}
+
+func newWorkspacesRunner(subcommand, dir string) runner {
+	// Silence the script banners npm prints before each script's output.
+	return newRunner("run").
+		withFlags(subcommand, "-w", dir, "--workspaces", "--env", "npm_config_loglevel=silent")
+}
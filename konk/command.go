@@ -9,19 +9,44 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 type Command struct {
 	cmd    *exec.Cmd
+	newCmd func() *exec.Cmd
 	out    strings.Builder
 	prefix string
+	name   string
+	logs   *logBuffer
+
+	mu         sync.Mutex
+	state      CommandState
+	restarts   int
+	stopping   bool
+	restarting bool
+	supervised bool
 }
 
+// CommandState describes where a command is in its lifecycle.
+type CommandState string
+
+const (
+	StatePending CommandState = "pending"
+	StateRunning CommandState = "running"
+	StateStopped CommandState = "stopped"
+	StateExited  CommandState = "exited"
+	StateFailed  CommandState = "failed"
+)
+
 var _ slog.LogValuer = (*Command)(nil)
 
 func (c *Command) LogValue() slog.Value {
@@ -31,9 +56,20 @@ func (c *Command) LogValue() slog.Value {
 	)
 }
 
+// Name returns the name used to address the command at runtime.
+func (c *Command) Name() string {
+	return c.name
+}
+
 type RunCommandConfig struct {
 	AggregateOutput bool
 	StopOnCancel    bool
+	// GroupHeader wraps aggregated output in a header and footer naming the
+	// command and how long it ran.
+	GroupHeader bool
+	// Flush, if set, is called with a command's aggregated output once it
+	// finishes, instead of printing it straight away.
+	Flush func(c *Command, out string)
 }
 
 type ShellCommandConfig struct {
@@ -42,18 +78,41 @@ type ShellCommandConfig struct {
 	NoColor bool
 	Env     []string
 	OmitEnv bool
+	// ID is the name used to address the command at runtime. It defaults to
+	// the label.
+	ID string
+	// Dir is the command's working directory. It defaults to konk's.
+	Dir string
+	// Shell is the shell that runs the command with -c. It defaults to
+	// DefaultShell.
+	Shell string
+	// ShellOpts is run by the shell before the command, e.g. "set -eu".
+	ShellOpts string
 }
 
+// DefaultShell is the shell commands run in unless another is given.
+const DefaultShell = "/bin/sh"
+
 func NewShellCommand(conf ShellCommandConfig) *Command {
-	c := exec.Command("/bin/sh", "-c", conf.Command) //nolint:gosec // Intentional user-defined sub-process.
-	setEnv(c, conf.Env, conf.OmitEnv)
-	prefix := getPrefix(conf.Label, conf.NoColor)
+	shell := conf.Shell
+	if shell == "" {
+		shell = DefaultShell
+	}
 
-	return &Command{
-		cmd:    c,
-		out:    strings.Builder{},
-		prefix: prefix,
+	script := conf.Command
+	if conf.ShellOpts != "" {
+		script = conf.ShellOpts + "\n" + script
+	}
+
+	newCmd := func() *exec.Cmd {
+		c := exec.Command(shell, "-c", script) //nolint:gosec // Intentional user-defined sub-process.
+		c.Dir = conf.Dir
+		setEnv(c, conf.Env, conf.OmitEnv)
+		setProcessGroup(c)
+		return c
 	}
+
+	return newRestartableCommand(newCmd, conf.ID, conf.Label, conf.NoColor)
 }
 
 type CommandConfig struct {
@@ -63,6 +122,11 @@ type CommandConfig struct {
 	NoColor bool
 	Env     []string
 	OmitEnv bool
+	// ID is the name used to address the command at runtime. It defaults to
+	// the label.
+	ID string
+	// Dir is the command's working directory. It defaults to konk's.
+	Dir string
 }
 
 func setEnv(c *exec.Cmd, env []string, omitEnv bool) {
@@ -73,15 +137,43 @@ func setEnv(c *exec.Cmd, env []string, omitEnv bool) {
 	c.Env = append(c.Env, env...)
 }
 
+// setProcessGroup runs a command in its own process group, so that it can be
+// stopped along with any processes it spawns (e.g. the children of a shell or
+// of "npm run").
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true} //nolint:exhaustruct // Only the process group is needed.
+}
+
 func NewCommand(conf CommandConfig) *Command {
-	cmd := exec.Command(conf.Name, conf.Args...) //nolint:gosec // Intentional user-defined sub-process.
-	setEnv(cmd, conf.Env, conf.OmitEnv)
-	prefix := getPrefix(conf.Label, conf.NoColor)
+	newCmd := func() *exec.Cmd {
+		cmd := exec.Command(conf.Name, conf.Args...) //nolint:gosec // Intentional user-defined sub-process.
+		cmd.Dir = conf.Dir
+		setEnv(cmd, conf.Env, conf.OmitEnv)
+		setProcessGroup(cmd)
+		return cmd
+	}
+
+	return newRestartableCommand(newCmd, conf.ID, conf.Label, conf.NoColor)
+}
+
+func newRestartableCommand(newCmd func() *exec.Cmd, name string, label string, noColor bool) *Command {
+	if name == "" {
+		name = strings.TrimSpace(label)
+	}
 
 	return &Command{
-		cmd:    cmd,
-		out:    strings.Builder{},
-		prefix: prefix,
+		cmd:        newCmd(),
+		newCmd:     newCmd,
+		out:        strings.Builder{},
+		prefix:     getPrefix(label, noColor),
+		name:       name,
+		logs:       newLogBuffer(),
+		mu:         sync.Mutex{},
+		state:      StatePending,
+		restarts:   0,
+		stopping:   false,
+		restarting: false,
+		supervised: false,
 	}
 }
 
@@ -98,10 +190,29 @@ func (c *Command) Run(ctx context.Context, cancel context.CancelFunc, conf RunCo
 	scannerErr := make(chan error)
 	allDone := make(chan error)
 
+	// Because the command runs in its own process group, it won't receive
+	// signals sent to konk's group (such as ^C in a terminal), so forward them.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var interrupted atomic.Bool
+
 	if err := c.cmd.Start(); err != nil {
+		c.setState(StateFailed)
+
+		// Commands printed in declared order wait on this one's output, so
+		// it must be flushed even though there is none.
+		if conf.AggregateOutput {
+			c.flush(conf, 0, true)
+		}
+
 		return fmt.Errorf("starting command: %w", err)
 	}
 
+	c.started()
+	start := time.Now()
+
 	// Start a goroutine to read the command's output. Send that output to the
 	// `out` channel and notify `scannerDone` when complete.
 	go func() {
@@ -111,6 +222,7 @@ func (c *Command) Run(ctx context.Context, cancel context.CancelFunc, conf RunCo
 
 		if err := scanner.Err(); err != nil {
 			scannerErr <- err
+			return
 		}
 
 		scannerDone <- true
@@ -123,6 +235,7 @@ func (c *Command) Run(ctx context.Context, cancel context.CancelFunc, conf RunCo
 		for {
 			select {
 			case t := <-out:
+				c.logs.write(t)
 				line := fmt.Sprintf("%s%s\n", c.prefix, t)
 
 				if conf.AggregateOutput {
@@ -130,16 +243,21 @@ func (c *Command) Run(ctx context.Context, cancel context.CancelFunc, conf RunCo
 				} else {
 					fmt.Fprint(os.Stdout, line)
 				}
+			case sig := <-signals:
+				interrupted.Store(true)
+				_ = c.signal(sig)
 			case <-ctx.Done():
 				if conf.StopOnCancel {
-					_ = c.cmd.Process.Signal(syscall.SIGTERM)
+					_ = c.signal(syscall.SIGTERM)
 					allDone <- nil
 					return
 				}
 			case err := <-scannerErr:
 				allDone <- err
+				return
 			case <-scannerDone:
 				allDone <- nil
+				return
 			}
 		}
 	}()
@@ -151,33 +269,215 @@ func (c *Command) Run(ctx context.Context, cancel context.CancelFunc, conf RunCo
 	// need not close the pipe themselves. It is thus incorrect to call Wait
 	// before all reads from the pipe have completed."
 	if err := <-allDone; err != nil {
+		if conf.AggregateOutput {
+			c.flush(conf, time.Since(start), true)
+		}
+
 		return err
 	}
 
+	err = c.cmd.Wait()
+	elapsed := time.Since(start)
+
+	// A command stopped or restarted on request has not failed, and must not
+	// bring down the rest of the session.
+	stopped := c.finish(err)
+
+	failed := err != nil && !stopped
+
 	if conf.AggregateOutput {
-		fmt.Fprint(os.Stdout, c.ReadOut())
+		c.flush(conf, elapsed, failed)
 	}
 
-	if err := c.cmd.Wait(); err != nil {
-		cancel()
+	if !failed {
+		return nil
+	}
 
-		var xerr *exec.ExitError
-		if errors.As(err, &xerr) {
-			exitErr := newExitError(c.prefix, xerr)
-			fmt.Fprintln(os.Stdout, exitErr)
-			return exitErr
-		}
+	cancel()
 
+	var xerr *exec.ExitError
+	if !errors.As(err, &xerr) {
 		return fmt.Errorf("waiting for command: %w", err)
 	}
 
-	return nil
+	exitErr := newExitError(c.prefix, xerr)
+	fmt.Fprintln(os.Stdout, exitErr)
+
+	if interrupted.Load() {
+		return fmt.Errorf("%w: %w", ErrInterrupted, exitErr)
+	}
+
+	return exitErr
+}
+
+// flush writes the command's aggregated output, wrapped in a header and footer
+// if requested.
+func (c *Command) flush(conf RunCommandConfig, elapsed time.Duration, failed bool) {
+	out := c.ReadOut()
+
+	if conf.GroupHeader {
+		outcome := "finished"
+		if failed {
+			outcome = "failed"
+		}
+
+		out = fmt.Sprintf("=== %s ===\n%s=== %s %s in %s ===\n",
+			c.name, out, c.name, outcome, elapsed.Round(time.Millisecond))
+	}
+
+	if conf.Flush != nil {
+		conf.Flush(c, out)
+		return
+	}
+
+	fmt.Fprint(os.Stdout, out)
 }
 
 func (c *Command) ReadOut() string {
 	return c.out.String()
 }
 
+// Status returns a snapshot of the command's runtime state.
+func (c *Command) Status() CommandStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pid int
+	if c.state == StateRunning && c.cmd.Process != nil {
+		pid = c.cmd.Process.Pid
+	}
+
+	return CommandStatus{
+		Name:     c.name,
+		State:    c.state,
+		PID:      pid,
+		Restarts: c.restarts,
+	}
+}
+
+// CommandStatus is a snapshot of a command's runtime state.
+type CommandStatus struct {
+	Name     string       `json:"name"`
+	State    CommandState `json:"state"`
+	PID      int          `json:"pid,omitempty"`
+	Restarts int          `json:"restarts"`
+}
+
+// Logs returns the command's recently buffered output lines. If follow is
+// true, it also returns a channel that receives new lines as they are written,
+// and a function that must be called to stop following.
+func (c *Command) Logs(follow bool) ([]string, <-chan string, func()) {
+	return c.logs.read(follow)
+}
+
+func (c *Command) setState(state CommandState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = state
+}
+
+// started records that the command is running, and stops it straight away if
+// a stop was requested while it was starting.
+func (c *Command) started() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = StateRunning
+
+	if c.stopping {
+		_ = c.signal(syscall.SIGTERM)
+	}
+}
+
+// finish records the outcome of a command run. It reports whether the command
+// exited because it was asked to stop.
+func (c *Command) finish(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stopped := c.stopping
+	c.stopping = false
+
+	switch {
+	case stopped:
+		c.state = StateStopped
+	case err != nil:
+		c.state = StateFailed
+	default:
+		c.state = StateExited
+	}
+
+	return stopped
+}
+
+var errNotRunning = errors.New("command is not running")
+
+// ErrInterrupted is returned when a command exits after konk forwarded it a
+// signal such as SIGINT.
+var ErrInterrupted = errors.New("interrupted")
+
+// signal sends a signal to the command's process group.
+func (c *Command) signal(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return c.cmd.Process.Signal(sig) //nolint:wrapcheck // Thin wrapper.
+	}
+
+	return syscall.Kill(-c.cmd.Process.Pid, s) //nolint:wrapcheck // Thin wrapper.
+}
+
+// signalStop asks a running command to terminate. If restart is true, the
+// command's supervisor starts it again once it has exited.
+//
+// c.mu must be held.
+func (c *Command) signalStop(restart bool) error {
+	switch c.state {
+	case StateRunning:
+	case StatePending:
+		// The command is about to start, so restarting it is moot, and stopping
+		// it takes effect once it has started.
+		if !restart {
+			c.stopping = true
+		}
+
+		return nil
+	case StateStopped, StateExited, StateFailed:
+		return fmt.Errorf("%s: %w", c.name, errNotRunning)
+	}
+
+	c.stopping = true
+	c.restarting = restart
+
+	if err := c.signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("signaling %s: %w", c.name, err)
+	}
+
+	return nil
+}
+
+// takeRestart reports whether a restart was requested, clearing the request.
+func (c *Command) takeRestart() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	restart := c.restarting
+	c.restarting = false
+
+	return restart
+}
+
+// reset prepares the command to be run again.
+func (c *Command) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cmd = c.newCmd()
+	c.out.Reset()
+	c.state = StatePending
+	c.restarts++
+}
+
 type ExitError struct {
 	label string
 	err   error
@@ -0,0 +1,221 @@
+package konk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// DefaultControlSocket is the path, relative to the working directory, of the
+// Unix socket a session's control server listens on.
+const DefaultControlSocket = ".konk.sock"
+
+// Control actions understood by the control server.
+const (
+	ControlStatus  = "status"
+	ControlRestart = "restart"
+	ControlStop    = "stop"
+	ControlLogs    = "logs"
+)
+
+// ControlRequest is a single request sent to a session's control server.
+type ControlRequest struct {
+	Action string   `json:"action"`
+	Names  []string `json:"names,omitempty"`
+	Follow bool     `json:"follow,omitempty"`
+}
+
+// ControlResponse is a message sent by a session's control server. A request
+// may receive several responses, such as one per log line.
+type ControlResponse struct {
+	Commands []CommandStatus `json:"commands,omitempty"`
+	Line     string          `json:"line,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type controlServer struct {
+	path     string
+	listener net.Listener
+	session  *session
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func listenControl(path string, s *session) (*controlServer, error) {
+	// A socket left behind by a session that didn't shut down cleanly can be
+	// removed, but one that still accepts connections belongs to a live session.
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("another session is listening on %s", path)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("removing stale control socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on control socket: %w", err)
+	}
+
+	return &controlServer{
+		path:     path,
+		listener: l,
+		session:  s,
+		done:     make(chan struct{}),
+		wg:       sync.WaitGroup{},
+	}, nil
+}
+
+func (srv *controlServer) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		srv.wg.Add(1)
+
+		go func() {
+			defer srv.wg.Done()
+			defer conn.Close()
+
+			srv.handle(conn)
+		}()
+	}
+}
+
+func (srv *controlServer) close() {
+	close(srv.done)
+	_ = srv.listener.Close()
+	srv.wg.Wait()
+}
+
+func (srv *controlServer) handle(conn net.Conn) {
+	enc := json.NewEncoder(conn)
+
+	var req ControlRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		_ = enc.Encode(ControlResponse{Commands: nil, Line: "", Error: fmt.Sprintf("decoding request: %s", err)})
+		return
+	}
+
+	if err := srv.dispatch(req, enc); err != nil {
+		_ = enc.Encode(ControlResponse{Commands: nil, Line: "", Error: err.Error()})
+	}
+}
+
+func (srv *controlServer) dispatch(req ControlRequest, enc *json.Encoder) error {
+	commands, err := srv.session.lookup(req.Names)
+	if err != nil {
+		return err
+	}
+
+	switch req.Action {
+	case ControlStatus:
+		return enc.Encode(ControlResponse{Commands: srv.session.status(), Line: "", Error: ""})
+	case ControlRestart, ControlStop:
+		for _, c := range commands {
+			if req.Action == ControlRestart {
+				err = srv.session.restart(c)
+			} else {
+				err = srv.session.stop(c)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return enc.Encode(ControlResponse{Commands: srv.session.status(), Line: "", Error: ""})
+	case ControlLogs:
+		if len(commands) != 1 {
+			return errors.New("logs requires exactly one command")
+		}
+
+		return srv.streamLogs(commands[0], req.Follow, enc)
+	default:
+		return fmt.Errorf("unknown action: %s", req.Action)
+	}
+}
+
+func (srv *controlServer) streamLogs(c *Command, follow bool, enc *json.Encoder) error {
+	lines, follower, unfollow := c.Logs(follow)
+	defer unfollow()
+
+	for _, line := range lines {
+		if err := enc.Encode(ControlResponse{Commands: nil, Line: line, Error: ""}); err != nil {
+			return err
+		}
+	}
+
+	if !follow {
+		return nil
+	}
+
+	for {
+		select {
+		case line := <-follower:
+			if err := enc.Encode(ControlResponse{Commands: nil, Line: line, Error: ""}); err != nil {
+				return err
+			}
+		case <-srv.done:
+			return nil
+		}
+	}
+}
+
+// SendControl sends a request to the control server listening on path, and
+// calls fn with each response it receives. It returns once the server closes
+// the connection, ctx is canceled, or fn returns an error.
+func SendControl(ctx context.Context, path string, req ControlRequest, fn func(ControlResponse) error) error {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return fmt.Errorf("connecting to control socket: %w", err)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(conn))
+
+	for {
+		var resp ControlResponse
+		if err := dec.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("reading response: %w", err)
+		}
+
+		if resp.Error != "" {
+			return errors.New(resp.Error)
+		}
+
+		if err := fn(resp); err != nil {
+			return err
+		}
+	}
+}
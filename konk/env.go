@@ -0,0 +1,154 @@
+package konk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jclem/konk/konk/internal/env"
+)
+
+// EnvVar is a variable defined in an env file.
+type EnvVar struct {
+	Key   string
+	Value string
+	// File is the path of the env file that defined the variable.
+	File string
+	// Line is the line on which the variable's definition starts.
+	Line int
+}
+
+// EnvLookupFunc looks up a variable referenced, but not defined, in an env file.
+type EnvLookupFunc = env.LookupFunc
+
+// EnvFile is an env file to load.
+type EnvFile struct {
+	Path string
+	// Optional files are skipped if they don't exist.
+	Optional bool
+}
+
+// LayeredEnvFiles returns the conventional, optional env files, in the order
+// they should be loaded: .env and .env.local, followed by .env.<mode> and
+// .env.<mode>.local if mode is not empty.
+func LayeredEnvFiles(mode string) []EnvFile {
+	paths := []string{".env", ".env.local"}
+	if mode != "" {
+		paths = append(paths, ".env."+mode, ".env."+mode+".local")
+	}
+
+	files := make([]EnvFile, len(paths))
+	for i, path := range paths {
+		files[i] = EnvFile{Path: path, Optional: true}
+	}
+
+	return files
+}
+
+// LoadEnv loads env files in order. A variable defined in a later file
+// overrides the same variable from an earlier one, and may refer to it. The
+// result is ordered by each variable's first definition.
+func LoadEnv(files []EnvFile, lookup EnvLookupFunc) ([]EnvVar, error) {
+	vars := []EnvVar{}
+	indexes := map[string]int{}
+
+	layeredLookup := func(key string) (string, bool) {
+		if i, ok := indexes[key]; ok {
+			return vars[i].Value, true
+		}
+
+		if lookup != nil {
+			return lookup(key)
+		}
+
+		return "", false
+	}
+
+	for _, file := range files {
+		fileVars, err := ReadEnvFile(file.Path, layeredLookup)
+		if file.Optional && errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range fileVars {
+			if i, ok := indexes[v.Key]; ok {
+				vars[i] = v
+				continue
+			}
+
+			indexes[v.Key] = len(vars)
+			vars = append(vars, v)
+		}
+	}
+
+	return vars, nil
+}
+
+// ReadEnvFile reads and parses the env file at path.
+func ReadEnvFile(path string, lookup EnvLookupFunc) ([]EnvVar, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading env file: %w", err)
+	}
+
+	parsed, err := env.Parse(string(src), lookup)
+	if err != nil {
+		return nil, fmt.Errorf("parsing env file %s: %w", path, err)
+	}
+
+	vars := make([]EnvVar, len(parsed))
+	for i, v := range parsed {
+		vars[i] = EnvVar{Key: v.Key, Value: v.Value, File: path, Line: v.Line}
+	}
+
+	return vars, nil
+}
+
+// EnvPairs converts variables into KEY=VALUE pairs.
+func EnvPairs(vars []EnvVar) []string {
+	pairs := make([]string, len(vars))
+	for i, v := range vars {
+		pairs[i] = v.Key + "=" + v.Value
+	}
+
+	return pairs
+}
+
+// dedupeEnv removes all but the last occurrence of each variable in env, a
+// list of KEY=VALUE pairs, keeping the order of those that remain.
+func dedupeEnv(env []string) []string {
+	seen := make(map[string]bool, len(env))
+	deduped := make([]string, 0, len(env))
+
+	for i := len(env) - 1; i >= 0; i-- {
+		key, _, _ := strings.Cut(env[i], "=")
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		deduped = append(deduped, env[i])
+	}
+
+	slices.Reverse(deduped)
+
+	return deduped
+}
+
+// LookupEnv returns the value of the last occurrence of key in env, a list of
+// KEY=VALUE pairs.
+func LookupEnv(env []string, key string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(env[i], "="); ok && k == key {
+			return v, true
+		}
+	}
+
+	return "", false
+}
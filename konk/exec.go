@@ -0,0 +1,48 @@
+package konk
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+type ExecConfig struct {
+	// Args holds the command to run and its arguments.
+	Args []string
+	// Env holds environment variables, as KEY=VALUE pairs, for the command.
+	Env     []string
+	OmitEnv bool
+}
+
+// Exec replaces the konk process with a command, which inherits konk's stdin,
+// stdout, stderr, and terminal. It only returns if the command can't be run.
+func Exec(cfg ExecConfig) error {
+	env := cfg.Env
+	if !cfg.OmitEnv {
+		env = append(os.Environ(), env...)
+	}
+
+	// Unlike exec.Cmd, syscall.Exec passes duplicate variables on as-is, and
+	// most programs would then see the first rather than the last.
+	env = dedupeEnv(env)
+
+	// Look the command up on the PATH it will run with, which an env file may
+	// have changed.
+	if path, ok := LookupEnv(env, "PATH"); ok {
+		if err := os.Setenv("PATH", path); err != nil {
+			return fmt.Errorf("setting PATH: %w", err)
+		}
+	}
+
+	name, err := exec.LookPath(cfg.Args[0])
+	if err != nil {
+		return fmt.Errorf("finding command: %w", err)
+	}
+
+	if err := syscall.Exec(name, cfg.Args, env); err != nil { //nolint:gosec // Intentional user-defined command.
+		return fmt.Errorf("executing %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -2,30 +2,345 @@ package env
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
-	"github.com/mattn/go-shellwords"
+	"github.com/jclem/konk/konk/internal/vars"
 )
 
-func Parse(env []string) ([]string, error) {
-	parsedEnv := make([]string, 0, len(env))
+// Var is a variable defined in an env file.
+type Var struct {
+	Key   string
+	Value string
+	// Line is the line on which the variable's definition starts.
+	Line int
+}
 
-	// Unquote any quoted .env vars.
-	for _, line := range env {
-		parsed, err := shellwords.Parse(line)
-		if err != nil {
-			return nil, fmt.Errorf("parsing .env line: %w", err)
+// ParseError describes a syntax error in an env file.
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// LookupFunc looks up a variable that is not defined earlier in an env file,
+// such as one from the process environment.
+type LookupFunc func(key string) (string, bool)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// Parse parses an env file. It supports:
+//
+//   - blank lines and comments (lines starting with "#", or " #" after an
+//     unquoted value)
+//   - an optional "export " prefix
+//   - unquoted, single-quoted, double-quoted, and backtick-quoted values, where
+//     quoted values may span multiple lines
+//   - escape sequences (\n, \r, \t, \", \\, \$) in double-quoted values
+//   - expansion of $NAME, ${NAME}, ${NAME:-default}, and ${NAME-default} in
+//     unquoted and double-quoted values, from variables defined earlier in the
+//     file or else from lookup
+//
+// Other forms of "${...}", such as "${NAME:+alt}" or "${#NAME}", are an error.
+//
+// If a variable is defined more than once, each definition is returned.
+func Parse(src string, lookup LookupFunc) ([]Var, error) {
+	p := parser{
+		src:    src,
+		pos:    0,
+		line:   1,
+		lookup: lookup,
+		values: map[string]string{},
+	}
+
+	return p.parse()
+}
+
+type parser struct {
+	src    string
+	pos    int
+	line   int
+	lookup LookupFunc
+	values map[string]string
+}
+
+func (p *parser) parse() ([]Var, error) {
+	parsed := []Var{}
+
+	for {
+		p.skipSpace(true)
+
+		if p.eof() {
+			return parsed, nil
 		}
 
-		if len(parsed) == 0 {
+		if p.peek() == '#' {
+			p.skipLine()
 			continue
 		}
 
-		if len(parsed) != 1 {
-			return nil, fmt.Errorf("invalid .env line: %s", line)
+		v, err := p.parseVar()
+		if err != nil {
+			return nil, err
 		}
 
-		parsedEnv = append(parsedEnv, parsed[0])
+		p.values[v.Key] = v.Value
+		parsed = append(parsed, v)
+	}
+}
+
+func (p *parser) parseVar() (v Var, err error) {
+	line := p.line
+	key := p.readKey()
+
+	if key == "export" && (p.peek() == ' ' || p.peek() == '\t') {
+		p.skipSpace(false)
+		key = p.readKey()
 	}
 
-	return parsedEnv, nil
+	if !keyPattern.MatchString(key) {
+		return v, p.errorf(line, "invalid variable name %q", key)
+	}
+
+	p.skipSpace(false)
+
+	if p.peek() != '=' {
+		return v, p.errorf(line, "expected \"=\" after %q", key)
+	}
+
+	p.pos++
+	p.skipSpace(false)
+
+	var value string
+
+	switch p.peek() {
+	case '\'', '`':
+		value, err = p.readRawQuoted()
+	case '"':
+		value, err = p.readDoubleQuoted()
+	default:
+		value, err = p.readUnquoted()
+	}
+
+	if err != nil {
+		return v, err
+	}
+
+	return Var{Key: key, Value: value, Line: line}, nil
+}
+
+// readKey reads up to the next character that can't appear in a key.
+func (p *parser) readKey() string {
+	start := p.pos
+
+	for !p.eof() && !strings.ContainsRune(" \t\r\n=#", rune(p.peek())) {
+		p.pos++
+	}
+
+	return p.src[start:p.pos]
+}
+
+// readRawQuoted reads a single-quoted or backtick-quoted value, which is taken
+// literally.
+func (p *parser) readRawQuoted() (string, error) {
+	line := p.line
+	quote := p.next()
+
+	end := strings.IndexByte(p.src[p.pos:], quote)
+	if end < 0 {
+		return "", p.errorf(line, "unterminated %c-quoted value", quote)
+	}
+
+	value := p.src[p.pos : p.pos+end]
+	p.line += strings.Count(value, "\n")
+	p.pos += end + 1
+
+	return value, p.endQuoted(line)
+}
+
+func (p *parser) readDoubleQuoted() (string, error) {
+	line := p.line
+	p.pos++
+
+	var b strings.Builder
+
+	for {
+		if p.eof() {
+			return "", p.errorf(line, "unterminated \"-quoted value")
+		}
+
+		c := p.next()
+
+		switch c {
+		case '"':
+			return b.String(), p.endQuoted(line)
+		case '\\':
+			if p.eof() {
+				continue
+			}
+
+			esc := p.next()
+
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(esc)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(esc)
+
+				if esc == '\n' {
+					p.line++
+				}
+			}
+		case '$':
+			if err := p.expand(&b, line); err != nil {
+				return "", err
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+
+			b.WriteByte(c)
+		}
+	}
+}
+
+// endQuoted ensures nothing but whitespace or a comment follows a quoted value.
+func (p *parser) endQuoted(line int) error {
+	p.skipSpace(false)
+
+	if p.eof() || p.peek() == '\n' || p.peek() == '\r' {
+		return nil
+	}
+
+	if p.peek() == '#' {
+		p.skipLine()
+		return nil
+	}
+
+	return p.errorf(line, "unexpected %q after quoted value", p.restOfLine())
+}
+
+func (p *parser) readUnquoted() (string, error) {
+	var b strings.Builder
+
+	for !p.eof() && p.peek() != '\n' {
+		c := p.next()
+
+		switch {
+		case c == '#' && (b.Len() == 0 || isSpace(p.src[p.pos-2])):
+			p.skipLine()
+		case c == '\\' && p.peek() == '$':
+			b.WriteByte(p.next())
+		case c == '$':
+			if err := p.expand(&b, p.line); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return strings.TrimRight(b.String(), " \t\r"), nil
+}
+
+// expand writes the value of the variable referenced just after a "$". An
+// unsupported reference is an error on line, where the definition starts.
+func (p *parser) expand(b *strings.Builder, line int) error {
+	value, n, err := vars.Expand(p.src[p.pos:], p.resolve)
+	if err != nil {
+		return p.errorf(line, "%s", err)
+	}
+
+	if n == 0 {
+		b.WriteByte('$')
+		return nil
+	}
+
+	p.pos += n
+	b.WriteString(value)
+
+	return nil
+}
+
+func (p *parser) resolve(name string) (string, bool) {
+	if value, ok := p.values[name]; ok {
+		return value, true
+	}
+
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+
+	return "", false
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+
+	return p.src[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+
+	return c
+}
+
+// skipSpace skips whitespace, including newlines if newlines is true.
+func (p *parser) skipSpace(newlines bool) {
+	for !p.eof() {
+		c := p.peek()
+
+		switch {
+		case c == '\n' && newlines:
+			p.line++
+		case c == ' ' || c == '\t' || c == '\r':
+		default:
+			return
+		}
+
+		p.pos++
+	}
+}
+
+// skipLine skips to the end of the current line, leaving the newline.
+func (p *parser) skipLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func (p *parser) restOfLine() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		return p.src[p.pos:]
+	}
+
+	return strings.TrimRight(p.src[p.pos:p.pos+end], "\r")
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	return &ParseError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
 }
@@ -0,0 +1,81 @@
+// Package vars expands references to variables, as in "$NAME" or
+// "${NAME:-default}", for both env files and commands.
+package vars
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupFunc looks up a variable. It reports whether the variable is set.
+type LookupFunc func(name string) (string, bool)
+
+// Expand returns the value of the variable referenced at the start of src,
+// which follows a "$", and the number of bytes the reference takes up. If src
+// doesn't start with a reference, n is 0 and the "$" is taken literally.
+//
+// The supported forms are $NAME, ${NAME}, ${NAME-default}, and
+// ${NAME:-default}. Other forms of "${...}", such as "${NAME:=default}" or
+// "${#NAME}", are an error rather than expanding to nothing.
+func Expand(src string, lookup LookupFunc) (string, int, error) {
+	if strings.HasPrefix(src, "{") {
+		end := strings.IndexAny(src, "}\n")
+		if end < 0 || src[end] != '}' {
+			rest := src
+			if end >= 0 {
+				rest = src[:end]
+			}
+
+			return "", 0, fmt.Errorf("unterminated %q", "$"+rest)
+		}
+
+		ref := src[1:end]
+
+		name, fallback, hasFallback := strings.Cut(ref, "-")
+		emptyIsUnset := hasFallback && strings.HasSuffix(name, ":")
+		name = strings.TrimSuffix(name, ":")
+
+		if !isName(name) || (!hasFallback && ref != name) {
+			return "", 0, fmt.Errorf("unsupported expansion ${%s}", ref)
+		}
+
+		value, ok := lookup(name)
+		if hasFallback && (!ok || (emptyIsUnset && value == "")) {
+			value = fallback
+		}
+
+		return value, end + 1, nil
+	}
+
+	n := 0
+	for n < len(src) && isNameByte(src[n], n == 0) {
+		n++
+	}
+
+	if n == 0 {
+		return "", 0, nil
+	}
+
+	value, _ := lookup(src[:n])
+
+	return value, n, nil
+}
+
+// isName reports whether s is a valid variable name.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := range len(s) {
+		if !isNameByte(s[i], i == 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (!first && c >= '0' && c <= '9')
+}
@@ -0,0 +1,269 @@
+package words
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/jclem/konk/konk/internal/vars"
+)
+
+// LookupFunc looks up a variable referenced in a command.
+type LookupFunc func(key string) (string, bool)
+
+// Options controls how words are expanded.
+type Options struct {
+	// Lookup looks up variables. If nil, every variable is unset.
+	Lookup LookupFunc
+	// Home replaces a leading "~". If empty, "~" is left as-is.
+	Home string
+	// Dir is the directory relative to which globs are matched. It defaults to
+	// the current directory.
+	Dir string
+}
+
+// Expand splits a command line into words as a POSIX shell would, without
+// running one. It supports:
+//
+//   - single-quoted strings, which are taken literally
+//   - double-quoted strings, in which "\" escapes "$", "`", "\"", and "\\"
+//   - backslash escapes outside of quotes
+//   - expansion of $NAME, ${NAME}, ${NAME:-default}, and ${NAME-default}
+//     outside of single quotes
+//   - expansion of "~" at the start of a word, alone or before "/"
+//   - filename globs ("*", "?", and "[...]") outside of quotes, which are left
+//     as-is if nothing matches
+//
+// Other forms of "${...}", such as "${NAME:=default}" or "${#NAME}", are an
+// error.
+//
+// Unlike in a shell, the values of variables aren't split into words or
+// matched as globs, and an unset variable outside of quotes expands to
+// nothing.
+func Expand(line string, opts Options) ([]string, error) {
+	p := parser{src: line, pos: 0, opts: opts, words: []string{}, word: word{}}
+
+	return p.parse()
+}
+
+// word is a word being read.
+type word struct {
+	// text is the word as written, once quotes and escapes are removed.
+	text strings.Builder
+	// pattern is text with glob characters that were quoted or escaped
+	// escaped once more, so that only the others are matched as globs.
+	pattern strings.Builder
+	// glob is whether the word holds a glob character outside of quotes.
+	glob bool
+	// started is whether anything, even an empty quoted string, has been read.
+	started bool
+}
+
+type parser struct {
+	src   string
+	pos   int
+	opts  Options
+	words []string
+	word  word
+}
+
+func (p *parser) parse() ([]string, error) {
+	for !p.eof() {
+		c := p.next()
+
+		switch c {
+		case ' ', '\t', '\n':
+			p.endWord()
+		case '\\':
+			if p.eof() {
+				p.writeLiteral("\\")
+			} else if esc := p.next(); esc != '\n' {
+				p.writeLiteral(string(esc))
+			}
+		case '\'':
+			if err := p.readSingleQuoted(); err != nil {
+				return nil, err
+			}
+		case '"':
+			if err := p.readDoubleQuoted(); err != nil {
+				return nil, err
+			}
+		case '$':
+			value, err := p.expand()
+			if err != nil {
+				return nil, err
+			}
+
+			// An unquoted variable that is empty doesn't make a word.
+			if value != "" {
+				p.writeLiteral(value)
+			}
+		case '~':
+			if !p.word.started && p.opts.Home != "" && (p.eof() || strings.IndexByte(" \t\n/", p.peek()) >= 0) {
+				p.writeLiteral(p.opts.Home)
+			} else {
+				p.writeLiteral("~")
+			}
+		case '*', '?', '[':
+			p.word.text.WriteByte(c)
+			p.word.pattern.WriteByte(c)
+			p.word.glob = true
+			p.word.started = true
+		case ']':
+			// Closes a bracket expression, so it mustn't be escaped.
+			p.word.text.WriteByte(c)
+			p.word.pattern.WriteByte(c)
+			p.word.started = true
+		default:
+			p.writeLiteral(string(c))
+		}
+	}
+
+	p.endWord()
+
+	return p.words, nil
+}
+
+func (p *parser) readSingleQuoted() error {
+	end := strings.IndexByte(p.src[p.pos:], '\'')
+	if end < 0 {
+		return errors.New("unterminated '-quoted string")
+	}
+
+	p.writeLiteral(p.src[p.pos : p.pos+end])
+	p.pos += end + 1
+
+	return nil
+}
+
+func (p *parser) readDoubleQuoted() error {
+	var b strings.Builder
+
+	for {
+		if p.eof() {
+			return errors.New("unterminated \"-quoted string")
+		}
+
+		c := p.next()
+
+		switch c {
+		case '"':
+			p.writeLiteral(b.String())
+			return nil
+		case '\\':
+			if !p.eof() && strings.IndexByte("$`\"\\\n", p.peek()) >= 0 {
+				if esc := p.next(); esc != '\n' {
+					b.WriteByte(esc)
+				}
+			} else {
+				b.WriteByte(c)
+			}
+		case '$':
+			value, err := p.expand()
+			if err != nil {
+				return err
+			}
+
+			b.WriteString(value)
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// expand returns the value of the variable referenced just after a "$". A "$"
+// that doesn't start a reference is taken literally.
+func (p *parser) expand() (string, error) {
+	value, n, err := vars.Expand(p.src[p.pos:], p.lookup)
+	if err != nil {
+		return "", err //nolint:wrapcheck // The message is complete as is.
+	}
+
+	if n == 0 {
+		return "$", nil
+	}
+
+	p.pos += n
+
+	return value, nil
+}
+
+func (p *parser) lookup(name string) (string, bool) {
+	if p.opts.Lookup == nil {
+		return "", false
+	}
+
+	return p.opts.Lookup(name)
+}
+
+// writeLiteral adds s to the current word, without matching it as a glob.
+func (p *parser) writeLiteral(s string) {
+	p.word.text.WriteString(s)
+
+	for _, c := range []byte(s) {
+		if strings.IndexByte(`*?[]\`, c) >= 0 {
+			p.word.pattern.WriteByte('\\')
+		}
+
+		p.word.pattern.WriteByte(c)
+	}
+
+	p.word.started = true
+}
+
+// endWord finishes the current word, if one has been started, replacing it
+// with the paths it matches if it's a glob.
+func (p *parser) endWord() {
+	if !p.word.started {
+		return
+	}
+
+	text, pattern, glob := p.word.text.String(), p.word.pattern.String(), p.word.glob
+	p.word = word{}
+
+	if glob {
+		if matches := p.glob(pattern); len(matches) > 0 {
+			p.words = append(p.words, matches...)
+			return
+		}
+	}
+
+	p.words = append(p.words, text)
+}
+
+// glob returns the paths matching pattern, relative to Dir if pattern is.
+func (p *parser) glob(pattern string) []string {
+	if filepath.IsAbs(pattern) || p.opts.Dir == "" {
+		matches, _ := filepath.Glob(pattern)
+		return matches
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(p.opts.Dir, pattern))
+
+	for i, match := range matches {
+		if rel, err := filepath.Rel(p.opts.Dir, match); err == nil {
+			matches[i] = rel
+		}
+	}
+
+	return matches
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+
+	return p.src[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+
+	return c
+}
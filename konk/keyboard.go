@@ -0,0 +1,94 @@
+package konk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const keyboardHelp = `Commands:
+  rs [name...]    restart commands (all commands if none are named)
+  stop <name...>  stop commands
+  ps              show the status of each command
+  clear           clear the screen
+  help            show this help`
+
+// serveKeyboard reads commands typed into r, one per line, and applies them to
+// the session. It returns when r is exhausted.
+func (s *session) serveKeyboard(r io.Reader, w io.Writer) {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if err := s.keyboardCommand(fields[0], fields[1:], w); err != nil {
+			fmt.Fprintf(w, "konk: %s\n", err)
+		}
+	}
+}
+
+func (s *session) keyboardCommand(name string, args []string, w io.Writer) error {
+	switch name {
+	case "rs", "restart", "stop":
+		if name == "stop" && len(args) == 0 {
+			return errors.New("usage: stop <name...>")
+		}
+
+		commands, err := s.lookup(args)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range commands {
+			if name == "stop" {
+				err = s.stop(c)
+			} else {
+				err = s.restart(c)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return WriteStatus(w, s.status())
+	case "ps":
+		return WriteStatus(w, s.status())
+	case "clear":
+		fmt.Fprint(w, "\033[H\033[2J")
+		return nil
+	case "help", "?":
+		fmt.Fprintln(w, keyboardHelp)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q (type \"help\" for a list of commands)", name)
+	}
+}
+
+// WriteStatus writes a table describing each command's runtime state.
+func WriteStatus(w io.Writer, statuses []CommandStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tPID\tRESTARTS")
+
+	for _, s := range statuses {
+		pid := "-"
+		if s.PID != 0 {
+			pid = strconv.Itoa(s.PID)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", s.Name, s.State, pid, s.Restarts)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package konk
+
+import "sync"
+
+// logBufferSize is the number of output lines retained for each command.
+const logBufferSize = 1000
+
+// logBuffer retains a command's most recent output lines and fans new lines
+// out to any followers.
+type logBuffer struct {
+	mu        sync.Mutex
+	lines     []string
+	followers map[chan string]struct{}
+}
+
+func newLogBuffer() *logBuffer {
+	return &logBuffer{
+		mu:        sync.Mutex{},
+		lines:     make([]string, 0),
+		followers: make(map[chan string]struct{}),
+	}
+}
+
+func (b *logBuffer) write(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) == logBufferSize {
+		b.lines = b.lines[1:]
+	}
+
+	b.lines = append(b.lines, line)
+
+	for f := range b.followers {
+		// Drop lines for followers that can't keep up rather than blocking the
+		// command's output.
+		select {
+		case f <- line:
+		default:
+		}
+	}
+}
+
+func (b *logBuffer) read(follow bool) ([]string, <-chan string, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := make([]string, len(b.lines))
+	copy(lines, b.lines)
+
+	if !follow {
+		return lines, nil, func() {}
+	}
+
+	f := make(chan string, logBufferSize)
+	b.followers[f] = struct{}{}
+
+	unfollow := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.followers, f)
+	}
+
+	return lines, f, unfollow
+}
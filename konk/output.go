@@ -0,0 +1,54 @@
+package konk
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// outputQueue holds commands' aggregated output so that it is printed in the
+// order the commands were declared, regardless of the order they finish in.
+type outputQueue struct {
+	mu      sync.Mutex
+	indexes map[*Command]int
+	blocks  []*string
+	next    int
+}
+
+func newOutputQueue(commands []*Command) *outputQueue {
+	indexes := make(map[*Command]int, len(commands))
+	for i, c := range commands {
+		indexes[c] = i
+	}
+
+	return &outputQueue{
+		mu:      sync.Mutex{},
+		indexes: indexes,
+		blocks:  make([]*string, len(commands)),
+		next:    0,
+	}
+}
+
+// flush records a command's output and prints every block that is no longer
+// waiting on an earlier command.
+func (q *outputQueue) flush(c *Command, out string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexes[c]
+
+	// A restarted command's earlier output has already been printed, so there
+	// is nothing left to order it against.
+	if i < q.next {
+		fmt.Fprint(os.Stdout, out)
+		return
+	}
+
+	q.blocks[i] = &out
+
+	for q.next < len(q.blocks) && q.blocks[q.next] != nil {
+		fmt.Fprint(os.Stdout, *q.blocks[q.next])
+		q.blocks[q.next] = nil
+		q.next++
+	}
+}
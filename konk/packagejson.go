@@ -0,0 +1,231 @@
+package konk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackageJSON holds the fields konk uses from a package.json file.
+type PackageJSON struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// PackageManager is the package manager and version used by Corepack, e.g.
+	// "pnpm@9.1.0".
+	PackageManager       string            `json:"packageManager"`
+	Scripts              Scripts           `json:"scripts"`
+	Workspaces           WorkspacePatterns `json:"workspaces"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+// ReadPackageJSON reads the package.json in dir.
+func ReadPackageJSON(dir string) (*PackageJSON, error) {
+	path := filepath.Join(dir, "package.json")
+
+	pkgFile, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var pkgJSON PackageJSON
+
+	if err := json.Unmarshal(pkgFile, &pkgJSON); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %w", path, err)
+	}
+
+	return &pkgJSON, nil
+}
+
+// DependencyNames returns the names of every package the package depends on,
+// including development, peer, and optional dependencies.
+func (p *PackageJSON) DependencyNames() []string {
+	var names []string
+
+	for _, deps := range []map[string]string{
+		p.Dependencies, p.DevDependencies, p.PeerDependencies, p.OptionalDependencies,
+	} {
+		for name := range deps {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Script is a package.json script.
+type Script struct {
+	Name    string
+	Command string
+}
+
+// Scripts is a package.json "scripts" object, which, unlike a map, keeps the
+// scripts in the order they are declared.
+type Scripts []Script
+
+// Names returns the names of the scripts.
+func (s Scripts) Names() []string {
+	names := make([]string, len(s))
+	for i, script := range s {
+		names[i] = script.Name
+	}
+
+	return names
+}
+
+// Lookup returns the command run by the named script.
+func (s Scripts) Lookup(name string) (string, bool) {
+	for _, script := range s {
+		if script.Name == name {
+			return script.Command, true
+		}
+	}
+
+	return "", false
+}
+
+func (s *Scripts) UnmarshalJSON(data []byte) error {
+	return decodeScripts(data, s, func(raw json.RawMessage) (string, error) {
+		var command string
+		if err := json.Unmarshal(raw, &command); err != nil {
+			return "", errors.New("script must be a string")
+		}
+
+		return command, nil
+	})
+}
+
+// decodeScripts decodes a JSON object of scripts into s, in order, reading
+// each script's command from its value with command.
+func decodeScripts(data []byte, s *Scripts, command func(raw json.RawMessage) (string, error)) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return errors.New("scripts must be an object")
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("reading script name: %w", err)
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("reading script %q: %w", key, err)
+		}
+
+		cmd, err := command(raw)
+		if err != nil {
+			return fmt.Errorf("reading script %q: %w", key, err)
+		}
+
+		//nolint:forcetypeassert // Object keys are always strings.
+		*s = append(*s, Script{Name: key.(string), Command: cmd})
+	}
+
+	return nil
+}
+
+// WorkspacePatterns is a package.json "workspaces" field, which npm and Yarn
+// write as a list of globs and Yarn classic also as {"packages": [...]}.
+type WorkspacePatterns []string
+
+func (w *WorkspacePatterns) UnmarshalJSON(data []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+
+	if err := json.Unmarshal(data, &object); err != nil {
+		return errors.New("workspaces must be a list or an object with a packages list")
+	}
+
+	*w = object.Packages
+
+	return nil
+}
+
+// DirectCommand returns a shell command that runs a script of the package in
+// dir the way "npm run" does, but without starting npm: it runs the script's
+// "pre" and "post" scripts around it, puts each node_modules/.bin from dir up
+// to the filesystem root on PATH, and sets npm's lifecycle variables. args are
+// appended to the script and must already be quoted for the shell.
+func (p *PackageJSON) DirectCommand(dir, script, args string) (string, error) {
+	if _, ok := p.Scripts.Lookup(script); !ok {
+		return "", fmt.Errorf("missing script: %s", script)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving directory: %w", err)
+	}
+
+	var bins []string
+
+	for d := dir; ; d = filepath.Dir(d) {
+		bins = append(bins, ShellQuote(filepath.Join(d, "node_modules", ".bin")))
+
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	exports := []string{
+		"PATH=" + strings.Join(bins, ":") + `:"$PATH"`,
+		"npm_command=run-script",
+		"npm_package_json=" + ShellQuote(filepath.Join(dir, "package.json")),
+		"npm_package_name=" + ShellQuote(p.Name),
+		"npm_package_version=" + ShellQuote(p.Version),
+	}
+
+	steps := []string{"export " + strings.Join(exports, " ")}
+
+	for _, step := range []struct{ event, args string }{
+		{"pre" + script, ""},
+		{script, args},
+		{"post" + script, ""},
+	} {
+		body, ok := p.Scripts.Lookup(step.event)
+		if !ok {
+			continue
+		}
+
+		// As with npm, the lifecycle script is the script as written, without
+		// the arguments it was given.
+		run := body
+		if step.args != "" {
+			run += " " + step.args
+		}
+
+		steps = append(steps, fmt.Sprintf("npm_lifecycle_event=%s npm_lifecycle_script=%s /bin/sh -c %s",
+			ShellQuote(step.event), ShellQuote(body), ShellQuote(run)))
+	}
+
+	return strings.Join(steps, " && "), nil
+}
+
+// ShellQuote quotes s for the shell, unless it is made up only of characters
+// the shell doesn't treat specially.
+func ShellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=+:,./@%") == "" {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,121 @@
+package konk
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackageManager is a JavaScript package manager that can run package.json
+// scripts.
+type PackageManager string
+
+const (
+	Npm  PackageManager = "npm"
+	Pnpm PackageManager = "pnpm"
+	// Yarn is either Yarn classic or Yarn Berry, which run scripts the same way.
+	Yarn PackageManager = "yarn"
+	Bun  PackageManager = "bun"
+)
+
+// PackageManagers lists the supported package managers.
+var PackageManagers = []PackageManager{Npm, Pnpm, Yarn, Bun}
+
+// lockfiles maps each lockfile to the package manager that writes it, in the
+// order they are checked.
+var lockfiles = []struct {
+	name string
+	pm   PackageManager
+}{
+	{"pnpm-lock.yaml", Pnpm},
+	{"yarn.lock", Yarn},
+	{".yarnrc.yml", Yarn},
+	{"bun.lock", Bun},
+	{"bun.lockb", Bun},
+	{"package-lock.json", Npm},
+	{"npm-shrinkwrap.json", Npm},
+}
+
+// ParsePackageManager parses a package manager name, such as "pnpm".
+func ParsePackageManager(name string) (PackageManager, error) {
+	for _, pm := range PackageManagers {
+		if string(pm) == name {
+			return pm, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown package manager %q", name)
+}
+
+// RunScript returns the command that runs a package.json script, passing it
+// args, which must already be quoted for the shell.
+func (pm PackageManager) RunScript(script string, args string) string {
+	cmd := string(pm) + " run " + script
+
+	switch {
+	case args == "":
+		return cmd
+	case pm == Npm:
+		// Without "--", npm would take options such as --watch for itself.
+		return cmd + " -- " + args
+	default:
+		return cmd + " " + args
+	}
+}
+
+// DetectPackageManager finds the package manager for the package in dir. It
+// looks in dir and then each of its parents for a package.json with a
+// "packageManager" field, as used by Corepack, or for a lockfile, and stops at
+// the first it finds. If there is neither, it returns Npm.
+func DetectPackageManager(dir string) (PackageManager, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving directory: %w", err)
+	}
+
+	for {
+		pm, err := packageManagerIn(dir)
+		if err != nil || pm != "" {
+			return pm, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Npm, nil
+		}
+
+		dir = parent
+	}
+}
+
+// packageManagerIn returns the package manager declared in or implied by the
+// files in dir, if any.
+func packageManagerIn(dir string) (PackageManager, error) {
+	pkgJSON, err := ReadPackageJSON(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	if err == nil {
+		// The field holds a name and version, e.g. "pnpm@9.1.0".
+		if name, _, _ := strings.Cut(pkgJSON.PackageManager, "@"); name != "" {
+			pm, err := ParsePackageManager(name)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", filepath.Join(dir, "package.json"), err)
+			}
+
+			return pm, nil
+		}
+	}
+
+	for _, lockfile := range lockfiles {
+		if _, err := os.Stat(filepath.Join(dir, lockfile.name)); err == nil {
+			return lockfile.pm, nil
+		}
+	}
+
+	return "", nil
+}
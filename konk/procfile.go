@@ -0,0 +1,207 @@
+package konk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ProcfileEntry is a single process type declared in a Procfile.
+type ProcfileEntry struct {
+	Name    string
+	Command string
+	Line    int
+}
+
+// ProcfileError describes an invalid line in a Procfile.
+type ProcfileError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ProcfileError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// procNamePattern matches valid process type names. As with foreman and
+// Heroku, names may only contain letters, digits, dashes, and underscores.
+var procNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ReadProcfile reads and parses the Procfile at path.
+func ReadProcfile(path string) ([]ProcfileEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening procfile: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := ParseProcfile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// ParseProcfile parses a Procfile, returning its entries in the order they are
+// declared. Blank lines and lines starting with "#" are ignored.
+func ParseProcfile(r io.Reader) ([]ProcfileEntry, error) {
+	entries := []ProcfileEntry{}
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(r)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, command, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, &ProcfileError{Line: lineNum, Msg: `expected "<name>: <command>"`}
+		}
+
+		name = strings.TrimSpace(name)
+		command = strings.TrimSpace(command)
+
+		if !procNamePattern.MatchString(name) {
+			return nil, &ProcfileError{
+				Line: lineNum,
+				Msg:  fmt.Sprintf("invalid process name %q (may only contain letters, digits, \"-\" and \"_\")", name),
+			}
+		}
+
+		if command == "" {
+			return nil, &ProcfileError{Line: lineNum, Msg: fmt.Sprintf("process %q has no command", name)}
+		}
+
+		if first, ok := seen[name]; ok {
+			return nil, &ProcfileError{
+				Line: lineNum,
+				Msg:  fmt.Sprintf("duplicate process name %q (first declared on line %d)", name, first),
+			}
+		}
+
+		seen[name] = lineNum
+		entries = append(entries, ProcfileEntry{Name: name, Command: command, Line: lineNum})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading procfile: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Formation maps process type names to the number of instances of each to run.
+// The special name "all" sets the count for every process type that isn't
+// named explicitly.
+type Formation map[string]int
+
+// formationAll is the formation name that applies to every process type.
+const formationAll = "all"
+
+// ParseFormation parses a formation such as "web=2,worker=3" or "all=2".
+func ParseFormation(s string) (Formation, error) {
+	formation := Formation{}
+
+	for _, part := range strings.Split(s, ",") {
+		name, count, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid formation %q: expected <name>=<count>", part)
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid formation %q: count must be a non-negative integer", part)
+		}
+
+		formation[name] = n
+	}
+
+	return formation, nil
+}
+
+// Count returns the number of instances of the named process type to run.
+func (f Formation) Count(name string) int {
+	if n, ok := f[name]; ok {
+		return n
+	}
+
+	if n, ok := f[formationAll]; ok {
+		return n
+	}
+
+	return 1
+}
+
+// Process is a single running instance of a Procfile entry.
+type Process struct {
+	Entry ProcfileEntry
+	// Type is the position of the entry in the Procfile, starting from 0.
+	Type int
+	// Index is the instance number, starting from 1.
+	Index int
+}
+
+// Name returns the process's instance name, such as "web.1".
+func (p Process) Name() string {
+	return fmt.Sprintf("%s.%d", p.Entry.Name, p.Index)
+}
+
+// DefaultBasePort is the port assigned to the first process when no base port
+// is configured.
+const DefaultBasePort = 5000
+
+// Port returns the port assigned to the process. As with foreman, each process
+// type is offset from the base port by 100, and each instance by 1.
+func (p Process) Port(base int) int {
+	return base + p.Type*100 + p.Index - 1
+}
+
+// Env returns the environment variables identifying the process instance and
+// the port assigned to it.
+func (p Process) Env(basePort int) []string {
+	return append([]string{
+		"PS=" + p.Name(),
+		"KONK_PROCESS_INDEX=" + strconv.Itoa(p.Index),
+	}, PortEnv(p.Port(basePort))...)
+}
+
+// PortEnv returns the variables that assign port to a command, as PORT and
+// KONK_PORT.
+func PortEnv(port int) []string {
+	value := strconv.Itoa(port)
+
+	return []string{"PORT=" + value, "KONK_PORT=" + value}
+}
+
+// Processes expands Procfile entries into the process instances described by
+// the formation, in Procfile order.
+func (f Formation) Processes(entries []ProcfileEntry) ([]Process, error) {
+	for name := range f {
+		if name == formationAll {
+			continue
+		}
+
+		if !slices.ContainsFunc(entries, func(e ProcfileEntry) bool { return e.Name == name }) {
+			return nil, fmt.Errorf("invalid formation: unknown process type %q", name)
+		}
+	}
+
+	processes := []Process{}
+
+	for i, entry := range entries {
+		for n := 1; n <= f.Count(entry.Name); n++ {
+			processes = append(processes, Process{Entry: entry, Type: i, Index: n})
+		}
+	}
+
+	return processes, nil
+}
@@ -2,47 +2,253 @@ package konk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
 
-	"github.com/jclem/konk/konk/internal/env"
+	"github.com/jclem/konk/konk/internal/words"
 	"github.com/mattn/go-shellwords"
-	"golang.org/x/sync/errgroup"
 )
 
 type RunConcurrentlyConfig struct {
-	Commands        []string
-	Labels          []string
-	Env             []string
+	Commands []string
+	Labels   []string
+	// Names are used to address commands at runtime, such as through the
+	// control socket. They default to the labels.
+	Names []string
+	// EnvVars holds environment variables, as KEY=VALUE pairs, for every
+	// command. EnvPairs converts variables read with LoadEnv.
+	EnvVars []string
+	// CommandEnv holds additional environment variables for each command.
+	CommandEnv [][]string
+	// Dirs holds each command's working directory. An empty directory means
+	// konk's own.
+	Dirs            []string
 	OmitEnv         bool
 	AggregateOutput bool
+	// OutputOrder controls the order in which aggregated output is printed.
+	OutputOrder OutputOrder
+	// GroupHeader wraps each command's aggregated output in a header and footer.
+	GroupHeader     bool
 	ContinueOnError bool
 	NoColor         bool
 	NoShell         bool
+	// Expand, with NoShell, expands variables, a leading "~", and globs in
+	// commands, as a shell would.
+	Expand bool
+	// Shells holds the shell each command runs in. An empty shell means
+	// DefaultShell.
+	Shells []string
+	// ShellOpts is run by each command's shell before the command.
+	ShellOpts string
+	// ControlSocket is the path of a Unix socket on which to serve the control
+	// API. If empty, no control server is started.
+	ControlSocket string
+	// Interactive enables commands typed on stdin, such as "rs web".
+	Interactive bool
+	// DependsOn holds, for each command, the indices of the commands that must
+	// finish successfully before it starts. A command whose dependencies fail
+	// is skipped.
+	DependsOn [][]int
 }
 
+// OutputOrder is the order in which concurrently run commands' aggregated
+// output is printed.
+type OutputOrder string
+
+const (
+	// OrderCompletion prints each command's output as soon as it finishes.
+	OrderCompletion OutputOrder = "completion"
+	// OrderDeclared prints each command's output in the order the commands were
+	// given, as soon as it and every command before it have finished.
+	OrderDeclared OutputOrder = "declared"
+)
+
 func RunConcurrently(ctx context.Context, cfg RunConcurrentlyConfig) ([]*Command, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	eg, ctx := errgroup.WithContext(ctx)
+	commands, err := newCommands(commandsConfig{
+		Commands:   cfg.Commands,
+		Labels:     cfg.Labels,
+		Names:      cfg.Names,
+		Env:        cfg.EnvVars,
+		CommandEnv: cfg.CommandEnv,
+		Dirs:       cfg.Dirs,
+		OmitEnv:    cfg.OmitEnv,
+		NoColor:    cfg.NoColor,
+		NoShell:    cfg.NoShell,
+		Expand:     cfg.Expand,
+		Shells:     cfg.Shells,
+		ShellOpts:  cfg.ShellOpts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var flush func(*Command, string)
+	if cfg.OutputOrder == OrderDeclared {
+		flush = newOutputQueue(commands).flush
+	}
 
-	commands := make([]*Command, len(cfg.Commands))
+	s := newSession(ctx, cancel, commands, cfg.DependsOn, RunCommandConfig{
+		AggregateOutput: cfg.AggregateOutput,
+		StopOnCancel:    !cfg.ContinueOnError,
+		GroupHeader:     cfg.GroupHeader,
+		Flush:           flush,
+	})
+
+	if cfg.ControlSocket != "" {
+		srv, err := listenControl(cfg.ControlSocket, s)
+		if err != nil {
+			return nil, err
+		}
+		defer srv.close()
+
+		go srv.serve()
+	}
+
+	if cfg.Interactive {
+		go s.serveKeyboard(os.Stdin, os.Stdout)
+	}
+
+	// Callers add their own context to the error, so it isn't wrapped here.
+	return commands, s.run()
+}
 
-	env, err := env.Parse(cfg.Env)
+type RunSeriallyConfig struct {
+	Commands []string
+	Labels   []string
+	// EnvVars holds environment variables, as KEY=VALUE pairs, for every
+	// command. EnvPairs converts variables read with LoadEnv.
+	EnvVars []string
+	// CommandEnv holds additional environment variables for each command.
+	CommandEnv [][]string
+	// Dirs holds each command's working directory. An empty directory means
+	// konk's own.
+	Dirs            []string
+	OmitEnv         bool
+	ContinueOnError bool
+	NoColor         bool
+	NoShell         bool
+	// Expand, with NoShell, expands variables, a leading "~", and globs in
+	// commands, as a shell would.
+	Expand bool
+	// Shells holds the shell each command runs in. An empty shell means
+	// DefaultShell.
+	Shells []string
+	// ShellOpts is run by each command's shell before the command.
+	ShellOpts string
+}
+
+// RunSerially runs commands one after another. Unless ContinueOnError is set,
+// it stops at the first command that fails.
+func RunSerially(ctx context.Context, cfg RunSeriallyConfig) ([]*Command, error) {
+	commands, err := newCommands(commandsConfig{
+		Commands:   cfg.Commands,
+		Labels:     cfg.Labels,
+		Names:      nil,
+		Env:        cfg.EnvVars,
+		CommandEnv: cfg.CommandEnv,
+		Dirs:       cfg.Dirs,
+		OmitEnv:    cfg.OmitEnv,
+		NoColor:    cfg.NoColor,
+		NoShell:    cfg.NoShell,
+		Expand:     cfg.Expand,
+		Shells:     cfg.Shells,
+		ShellOpts:  cfg.ShellOpts,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parsing env: %w", err)
+		return nil, err
 	}
 
+	var errCmd error
+
+	for _, c := range commands {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		err := c.Run(ctx, cancel, RunCommandConfig{
+			AggregateOutput: false,
+			StopOnCancel:    false,
+			GroupHeader:     false,
+			Flush:           nil,
+		})
+
+		// An interrupted command means the user wants konk to stop, even if
+		// failures are otherwise being ignored.
+		if err != nil && cfg.ContinueOnError && !errors.Is(err, ErrInterrupted) {
+			errCmd = err
+		} else if err != nil {
+			return commands, err
+		}
+	}
+
+	return commands, errCmd
+}
+
+// commandsConfig describes a set of commands to build.
+type commandsConfig struct {
+	Commands   []string
+	Labels     []string
+	Names      []string
+	Env        []string
+	CommandEnv [][]string
+	Dirs       []string
+	OmitEnv    bool
+	NoColor    bool
+	NoShell    bool
+	Expand     bool
+	Shells     []string
+	ShellOpts  string
+}
+
+func newCommands(cfg commandsConfig) ([]*Command, error) {
+	commands := make([]*Command, len(cfg.Commands))
+
 	for i, cmd := range cfg.Commands {
 		var c *Command
 
+		var name string
+		if len(cfg.Names) > 0 {
+			name = cfg.Names[i]
+		}
+
+		var dir string
+		if len(cfg.Dirs) > 0 {
+			dir = cfg.Dirs[i]
+		}
+
+		var shell string
+		if len(cfg.Shells) > 0 {
+			shell = cfg.Shells[i]
+		}
+
+		env := cfg.Env
+		if len(cfg.CommandEnv) > 0 {
+			env = append(slices.Clip(env), cfg.CommandEnv[i]...)
+		}
+
 		if cfg.NoShell {
-			parts, err := shellwords.Parse(cmd)
+			var parts []string
+			var err error
+
+			if cfg.Expand {
+				parts, err = expandCommand(cmd, env, cfg.OmitEnv, dir)
+			} else {
+				parts, err = shellwords.Parse(cmd)
+			}
 
 			if err != nil {
 				return nil, fmt.Errorf("parsing command: %w", err)
 			}
 
+			if len(parts) == 0 {
+				return nil, fmt.Errorf("parsing command: %q is empty", cmd)
+			}
+
 			c = NewCommand(CommandConfig{
 				Name:    parts[0],
 				Args:    parts[1:],
@@ -50,33 +256,55 @@ func RunConcurrently(ctx context.Context, cfg RunConcurrentlyConfig) ([]*Command
 				Env:     env,
 				OmitEnv: cfg.OmitEnv,
 				NoColor: cfg.NoColor,
+				ID:      name,
+				Dir:     dir,
 			})
 		} else {
 			c = NewShellCommand(ShellCommandConfig{
-				Command: cmd,
-				Label:   cfg.Labels[i],
-				Env:     env,
-				OmitEnv: cfg.OmitEnv,
-				NoColor: cfg.NoColor,
+				Command:   cmd,
+				Label:     cfg.Labels[i],
+				Env:       env,
+				OmitEnv:   cfg.OmitEnv,
+				NoColor:   cfg.NoColor,
+				ID:        name,
+				Dir:       dir,
+				Shell:     shell,
+				ShellOpts: cfg.ShellOpts,
 			})
 		}
 
+		if c.name == "" {
+			c.name = strconv.Itoa(i)
+		}
+
 		commands[i] = c
 	}
 
-	for _, cmd := range commands {
-		eg.Go(func() error {
-			return cmd.Run(ctx, cancel, RunCommandConfig{
-				AggregateOutput: cfg.AggregateOutput,
-				StopOnCancel:    !cfg.ContinueOnError,
-			})
-		})
+	return commands, nil
+}
+
+// expandCommand splits a command into its name and arguments, expanding
+// variables from env (and, unless omitEnv is set, konk's environment), a
+// leading "~", and globs relative to dir.
+func expandCommand(cmd string, env []string, omitEnv bool, dir string) ([]string, error) {
+	lookup := func(key string) (string, bool) {
+		if value, ok := LookupEnv(env, key); ok {
+			return value, true
+		}
+
+		if omitEnv {
+			return "", false
+		}
+
+		return os.LookupEnv(key)
 	}
 
-	err = eg.Wait()
+	home, _ := lookup("HOME")
+
+	parts, err := words.Expand(cmd, words.Options{Lookup: lookup, Home: home, Dir: dir})
 	if err != nil {
-		err = fmt.Errorf("running commands: %w", err)
+		return nil, fmt.Errorf("expanding command: %w", err)
 	}
 
-	return commands, err
+	return parts, nil
 }
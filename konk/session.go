@@ -0,0 +1,284 @@
+package konk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// session supervises a set of concurrently running commands. Unlike a plain
+// errgroup, it allows individual commands to be stopped and restarted while
+// the rest of the set keeps running.
+type session struct {
+	ctx      context.Context //nolint:containedctx // The session outlives any single call.
+	cancel   context.CancelFunc
+	commands []*Command
+	conf     RunCommandConfig
+	// dependsOn holds, for each command, the indices of the commands that must
+	// succeed before it starts.
+	dependsOn [][]int
+
+	mu        sync.Mutex
+	running   int
+	closed    bool
+	err       error
+	done      chan struct{}
+	exited    []chan struct{}
+	succeeded []bool
+}
+
+var errSessionClosed = errors.New("session has finished")
+
+func newSession(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	commands []*Command,
+	dependsOn [][]int,
+	conf RunCommandConfig,
+) *session {
+	exited := make([]chan struct{}, len(commands))
+	for i := range exited {
+		exited[i] = make(chan struct{})
+	}
+
+	return &session{
+		ctx:       ctx,
+		cancel:    cancel,
+		commands:  commands,
+		conf:      conf,
+		dependsOn: dependsOn,
+		mu:        sync.Mutex{},
+		running:   0,
+		closed:    false,
+		err:       nil,
+		done:      make(chan struct{}),
+		exited:    exited,
+		succeeded: make([]bool, len(commands)),
+	}
+}
+
+// run starts every command and waits until all of them have finished,
+// returning the first error encountered.
+func (s *session) run() error {
+	if len(s.commands) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.running = len(s.commands)
+	s.mu.Unlock()
+
+	for i, c := range s.commands {
+		c.mu.Lock()
+		c.supervised = true
+		c.mu.Unlock()
+
+		go s.start(i)
+	}
+
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// start waits for the commands that command i depends on and then supervises
+// it. If any of them fails, or the session shuts down first, the command is
+// skipped.
+func (s *session) start(i int) {
+	c := s.commands[i]
+
+	if len(s.dependsOn) > 0 {
+		for _, dep := range s.dependsOn[i] {
+			select {
+			case <-s.exited[dep]:
+			case <-s.ctx.Done():
+				// Unless the session is stopping, a failure elsewhere doesn't
+				// affect this command.
+				if !s.stopping() {
+					<-s.exited[dep]
+				}
+			}
+
+			if s.stopping() || !s.hasSucceeded(dep) {
+				c.mu.Lock()
+				c.supervised = false
+				c.mu.Unlock()
+
+				// Output printed in declared order waits on every command, so
+				// a skipped one still takes its turn, with nothing to print.
+				if s.conf.AggregateOutput && s.conf.Flush != nil {
+					s.conf.Flush(c, "")
+				}
+
+				s.exit(i, false)
+				s.finish(nil)
+
+				return
+			}
+		}
+	}
+
+	s.supervise(c)
+}
+
+// supervise runs a command, running it again for as long as restarts are
+// requested.
+func (s *session) supervise(c *Command) {
+	for {
+		err := c.Run(s.ctx, s.cancel, s.conf)
+
+		if err == nil && c.takeRestart() && !s.stopping() {
+			c.reset()
+			continue
+		}
+
+		c.mu.Lock()
+		c.supervised = false
+		c.mu.Unlock()
+
+		s.exit(slices.Index(s.commands, c), err == nil)
+		s.finish(err)
+
+		return
+	}
+}
+
+// exit records that command i has finished for the first time, releasing the
+// commands that depend on it.
+func (s *session) exit(i int, succeeded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.exited[i]:
+		// The command has been restarted since it first finished.
+	default:
+		s.succeeded[i] = succeeded
+		close(s.exited[i])
+	}
+}
+
+func (s *session) hasSucceeded(i int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.succeeded[i]
+}
+
+func (s *session) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil && s.err == nil {
+		s.err = err
+		s.cancel()
+	}
+
+	s.running--
+
+	if s.running == 0 {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// stopping reports whether the session is shutting down after a failure.
+func (s *session) stopping() bool {
+	return s.conf.StopOnCancel && s.ctx.Err() != nil
+}
+
+// lookup finds commands by name. A name also matches every instance of a
+// process type, so "web" matches "web.1" and "web.2". With no names, lookup
+// returns every command.
+func (s *session) lookup(names []string) ([]*Command, error) {
+	if len(names) == 0 {
+		return s.commands, nil
+	}
+
+	commands := make([]*Command, 0, len(names))
+
+	for _, name := range names {
+		var found []*Command
+
+		for _, c := range s.commands {
+			if c.name == name {
+				found = []*Command{c}
+				break
+			}
+
+			if strings.HasPrefix(c.name, name+".") {
+				found = append(found, c)
+			}
+		}
+
+		if len(found) == 0 {
+			return nil, fmt.Errorf("unknown command: %s", name)
+		}
+
+		commands = append(commands, found...)
+	}
+
+	return commands, nil
+}
+
+func (s *session) status() []CommandStatus {
+	statuses := make([]CommandStatus, len(s.commands))
+
+	for i, c := range s.commands {
+		statuses[i] = c.Status()
+	}
+
+	return statuses
+}
+
+func (s *session) stop(c *Command) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.signalStop(false)
+}
+
+// restart restarts a running command, or starts a finished one again.
+func (s *session) restart(c *Command) error {
+	c.mu.Lock()
+
+	if c.supervised {
+		defer c.mu.Unlock()
+		return c.signalStop(true)
+	}
+
+	c.supervised = true
+	c.mu.Unlock()
+
+	if err := s.add(); err != nil {
+		c.mu.Lock()
+		c.supervised = false
+		c.mu.Unlock()
+
+		return err
+	}
+
+	c.reset()
+	go s.supervise(c)
+
+	return nil
+}
+
+func (s *session) add() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.stopping() {
+		return errSessionClosed
+	}
+
+	s.running++
+
+	return nil
+}
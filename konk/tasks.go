@@ -0,0 +1,370 @@
+package konk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TaskSource is a kind of file that declares tasks, such as a Makefile.
+type TaskSource interface {
+	// Name returns the name of the kind of file, such as "Makefile".
+	Name() string
+	// Tasks returns the tasks declared in dir, in the order they are declared.
+	// A task's command is set only if the source declares it as a single
+	// command line.
+	Tasks(dir string) (Scripts, error)
+	// RunTask returns the command that runs a task, passing it args, which
+	// must already be quoted for the shell.
+	RunTask(task string, args string) string
+}
+
+var (
+	// MakeTasks is the targets in a Makefile, run with make.
+	MakeTasks TaskSource = makeSource{}
+	// JustTasks is the recipes in a justfile, run with just.
+	JustTasks TaskSource = justSource{}
+	// TaskfileTasks is the tasks in a Taskfile, run with task.
+	TaskfileTasks TaskSource = taskfileSource{}
+	// DenoTasks is the tasks in deno.json, run with deno task.
+	DenoTasks TaskSource = denoSource{}
+)
+
+// readTaskFile reads the first of names that exists in dir.
+func readTaskFile(dir string, names ...string) (string, []byte, error) {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+
+		src, err := os.ReadFile(path)
+		if err == nil {
+			return path, src, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	return "", nil, fmt.Errorf("no %s in %s: %w", names[0], filepath.Clean(dir), fs.ErrNotExist)
+}
+
+// logicalLines splits src into lines, joining a line that ends in "\\" with the
+// one after it.
+func logicalLines(src []byte) []string {
+	var lines []string
+
+	var line strings.Builder
+
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if cont, ok := strings.CutSuffix(text, "\\"); ok {
+			line.WriteString(cont)
+			line.WriteByte(' ')
+
+			continue
+		}
+
+		line.WriteString(text)
+		lines = append(lines, line.String())
+		line.Reset()
+	}
+
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+
+	return lines
+}
+
+// appendArgs appends args, if any, to a command.
+func appendArgs(cmd string, args string) string {
+	if args == "" {
+		return cmd
+	}
+
+	return cmd + " " + args
+}
+
+type makeSource struct{}
+
+func (makeSource) Name() string { return "Makefile" }
+
+// makeRulePattern matches a rule's targets, but not a variable assignment such
+// as "CC := gcc".
+var makeRulePattern = regexp.MustCompile(`^([^\s:#=][^:#=]*?)\s*::?(?:[^=]|$)`)
+
+// makeDirectivePattern matches a line starting with a directive, such as a
+// conditional, that isn't a rule even if it holds a ":".
+var makeDirectivePattern = regexp.MustCompile(
+	`^\s*(?:(?:override|export|private)\s+)*` +
+		`(?:ifeq|ifneq|ifdef|ifndef|else|endif|define|endef|include|-include|sinclude|vpath|undefine)(?:\s|$)`)
+
+func (makeSource) Tasks(dir string) (Scripts, error) {
+	_, src, err := readTaskFile(dir, "GNUmakefile", "makefile", "Makefile")
+	if err != nil {
+		return nil, err
+	}
+
+	var targets Scripts
+
+	// defines counts the "define" blocks the line is in, whose lines are a
+	// variable's value rather than rules.
+	defines := 0
+
+	for _, line := range logicalLines(src) {
+		if directive := makeDirectivePattern.FindString(line); directive != "" {
+			fields := strings.Fields(directive)
+
+			switch fields[len(fields)-1] {
+			case "define":
+				defines++
+			case "endef":
+				defines = max(defines-1, 0)
+			}
+
+			continue
+		}
+
+		if defines > 0 {
+			continue
+		}
+
+		match := makeRulePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		for _, target := range strings.Fields(match[1]) {
+			// Skip special targets such as .PHONY, pattern rules, and targets
+			// named by variables.
+			if _, ok := targets.Lookup(target); ok ||
+				strings.HasPrefix(target, ".") || strings.ContainsAny(target, "%$") {
+				continue
+			}
+
+			targets = append(targets, Script{Name: target, Command: ""})
+		}
+	}
+
+	return targets, nil
+}
+
+func (makeSource) RunTask(task string, args string) string {
+	// Make would take arguments as more targets, so they're passed in the ARGS
+	// variable instead.
+	if args == "" {
+		return "make " + task
+	}
+
+	return "make " + task + " ARGS=" + ShellQuote(args)
+}
+
+type justSource struct{}
+
+func (justSource) Name() string { return "justfile" }
+
+// justRecipePattern matches a recipe's name, but not an assignment such as
+// "version := '1.0'". Its parameters' defaults may be quoted strings that hold
+// a ":".
+var justRecipePattern = regexp.MustCompile(
+	`^@?([A-Za-z][A-Za-z0-9_-]*)(?:\s(?:[^:"'\x60]|"(?:[^"\\]|\\.)*"|'[^']*'|\x60[^\x60]*\x60)*)?:(?:[^=]|$)`)
+
+// justStringDelims are the delimiters of strings that may span lines.
+var justStringDelims = []string{`"""`, `'''`, "```"}
+
+func (justSource) Tasks(dir string) (Scripts, error) {
+	_, src, err := readTaskFile(dir, "justfile", "Justfile", ".justfile")
+	if err != nil {
+		return nil, err
+	}
+
+	var recipes Scripts
+
+	// open is the delimiter of the multi-line string the line is in, if any.
+	open := ""
+
+	for _, line := range logicalLines(src) {
+		inString := open != ""
+		open = justOpenString(line, open)
+
+		if inString {
+			continue
+		}
+
+		if match := justRecipePattern.FindStringSubmatch(line); match != nil {
+			recipes = append(recipes, Script{Name: match[1], Command: ""})
+		}
+	}
+
+	return recipes, nil
+}
+
+// justOpenString returns the delimiter of the multi-line string left open at
+// the end of line, given the one open at its start.
+func justOpenString(line string, open string) string {
+	for {
+		if open != "" {
+			end := strings.Index(line, open)
+			if end < 0 {
+				return open
+			}
+
+			line, open = line[end+len(open):], ""
+		}
+
+		start := -1
+
+		for _, delim := range justStringDelims {
+			if i := strings.Index(line, delim); i >= 0 && (start < 0 || i < start) {
+				start, open = i, delim
+			}
+		}
+
+		if start < 0 {
+			return ""
+		}
+
+		line = line[start+len(open):]
+	}
+}
+
+func (justSource) RunTask(task string, args string) string {
+	return appendArgs("just "+task, args)
+}
+
+type taskfileSource struct{}
+
+func (taskfileSource) Name() string { return "Taskfile" }
+
+func (taskfileSource) Tasks(dir string) (Scripts, error) {
+	path, src, err := readTaskFile(dir,
+		"Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml", "Taskfile.dist.yml", "Taskfile.dist.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	var taskfile struct {
+		// A node, unlike a map, keeps the tasks in order.
+		Tasks yaml.Node `yaml:"tasks"`
+	}
+
+	if err := yaml.Unmarshal(src, &taskfile); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var tasks Scripts
+
+	// A mapping node's content alternates between keys and values.
+	for i := 0; i+1 < len(taskfile.Tasks.Content); i += 2 {
+		tasks = append(tasks, Script{Name: taskfile.Tasks.Content[i].Value, Command: ""})
+	}
+
+	return tasks, nil
+}
+
+func (taskfileSource) RunTask(task string, args string) string {
+	// Task passes arguments after "--" to tasks as CLI_ARGS.
+	if args == "" {
+		return "task " + task
+	}
+
+	return "task " + task + " -- " + args
+}
+
+type denoSource struct{}
+
+func (denoSource) Name() string { return "deno.json" }
+
+func (denoSource) Tasks(dir string) (Scripts, error) {
+	path, src, err := readTaskFile(dir, "deno.json", "deno.jsonc")
+	if err != nil {
+		return nil, err
+	}
+
+	var denoJSON struct {
+		Tasks denoTasks `json:"tasks"`
+	}
+
+	if err := json.Unmarshal(stripJSONComments(src), &denoJSON); err != nil {
+		return nil, fmt.Errorf("unmarshalling %s: %w", path, err)
+	}
+
+	return Scripts(denoJSON.Tasks), nil
+}
+
+// denoTasks is a deno.json "tasks" object, whose tasks are each written as a
+// command or as an object such as {"command": "...", "description": "..."}.
+type denoTasks Scripts
+
+func (t *denoTasks) UnmarshalJSON(data []byte) error {
+	return decodeScripts(data, (*Scripts)(t), func(raw json.RawMessage) (string, error) {
+		var command string
+		if err := json.Unmarshal(raw, &command); err == nil {
+			return command, nil
+		}
+
+		// A task with only dependencies has no command.
+		var task struct {
+			Command string `json:"command"`
+		}
+
+		if err := json.Unmarshal(raw, &task); err != nil {
+			return "", errors.New("task must be a string or an object")
+		}
+
+		return task.Command, nil
+	})
+}
+
+func (denoSource) RunTask(task string, args string) string {
+	return appendArgs("deno task "+task, args)
+}
+
+// stripJSONComments removes // and /* */ comments from JSONC, leaving strings
+// intact.
+func stripJSONComments(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '"':
+			start := i
+
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+
+			out = append(out, src[start:min(i+1, len(src))]...)
+		case bytes.HasPrefix(src[i:], []byte("//")):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+			out = append(out, '\n')
+		case bytes.HasPrefix(src[i:], []byte("/*")):
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+
+			i += end + 3
+		default:
+			out = append(out, src[i])
+		}
+	}
+
+	return out
+}
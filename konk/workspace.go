@@ -0,0 +1,263 @@
+package konk
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace is a package in a monorepo.
+type Workspace struct {
+	Name string
+	// Dir is the package's directory, relative to the monorepo root.
+	Dir     string
+	Package *PackageJSON
+}
+
+// FindWorkspaces finds the packages in the monorepo at root, from the
+// "packages" globs in pnpm-workspace.yaml or else the "workspaces" globs in
+// package.json. Globs starting with "!" exclude packages, and a trailing "/**"
+// matches packages at any depth. Packages are returned in the order their globs
+// match them.
+func FindWorkspaces(root string) ([]Workspace, error) {
+	patterns, err := workspacePatterns(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var includes, excludes []string
+
+	for _, pattern := range patterns {
+		if exclude, ok := strings.CutPrefix(pattern, "!"); ok {
+			excludes = append(excludes, filepath.Clean(exclude))
+		} else {
+			includes = append(includes, pattern)
+		}
+	}
+
+	var workspaces []Workspace
+
+	for _, pattern := range includes {
+		dirs, err := globWorkspaceDirs(root, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dir := range dirs {
+			if slices.ContainsFunc(workspaces, func(w Workspace) bool { return w.Dir == dir }) ||
+				slices.ContainsFunc(excludes, func(exclude string) bool { return matchWorkspace(exclude, dir) }) {
+				continue
+			}
+
+			pkgJSON, err := ReadPackageJSON(filepath.Join(root, dir))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			name := pkgJSON.Name
+			if name == "" {
+				name = filepath.Base(dir)
+			}
+
+			workspaces = append(workspaces, Workspace{Name: name, Dir: dir, Package: pkgJSON})
+		}
+	}
+
+	return workspaces, nil
+}
+
+func workspacePatterns(root string) ([]string, error) {
+	path := filepath.Join(root, "pnpm-workspace.yaml")
+
+	src, err := os.ReadFile(path)
+	if err == nil {
+		var pnpmWorkspace struct {
+			Packages []string `yaml:"packages"`
+		}
+
+		if err := yaml.Unmarshal(src, &pnpmWorkspace); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+
+		return pnpmWorkspace.Packages, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	pkgJSON, err := ReadPackageJSON(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgJSON.Workspaces) == 0 {
+		return nil, fmt.Errorf("no workspaces declared in %s or %s", path, filepath.Join(root, "package.json"))
+	}
+
+	return pkgJSON.Workspaces, nil
+}
+
+// globWorkspaceDirs returns the directories under root matching pattern,
+// relative to root.
+func globWorkspaceDirs(root, pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+
+	if base, ok := strings.CutSuffix(pattern, string(filepath.Separator)+"**"); ok {
+		var dirs []string
+
+		err := filepath.WalkDir(filepath.Join(root, base), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() {
+				return nil
+			}
+
+			if d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+
+			dirs = append(dirs, rel)
+
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("finding workspaces matching %q: %w", pattern, err)
+		}
+
+		return dirs, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+	}
+
+	dirs := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		// Patterns such as "packages/*" also match files, like a README.
+		if info, err := os.Stat(match); err != nil || !info.IsDir() {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, match)
+		if err != nil {
+			return nil, fmt.Errorf("resolving workspace %s: %w", match, err)
+		}
+
+		dirs = append(dirs, rel)
+	}
+
+	return dirs, nil
+}
+
+// matchWorkspace reports whether dir matches an exclusion pattern.
+func matchWorkspace(pattern, dir string) bool {
+	if base, ok := strings.CutSuffix(pattern, string(filepath.Separator)+"**"); ok {
+		return dir == base || strings.HasPrefix(dir, base+string(filepath.Separator))
+	}
+
+	ok, _ := filepath.Match(pattern, dir)
+
+	return ok
+}
+
+// SortWorkspaces orders workspaces so that each comes after the workspaces it
+// depends on, otherwise keeping their order. It returns an error if the
+// dependencies form a cycle.
+func SortWorkspaces(workspaces []Workspace) ([]Workspace, error) {
+	sorted := make([]Workspace, 0, len(workspaces))
+	visiting := make(map[string]bool, len(workspaces))
+	visited := make(map[string]bool, len(workspaces))
+	byName := workspacesByName(workspaces)
+
+	var visit func(w Workspace, path []string) error
+	visit = func(w Workspace, path []string) error {
+		if visited[w.Name] {
+			return nil
+		}
+
+		path = append(path, w.Name)
+
+		if visiting[w.Name] {
+			return fmt.Errorf("workspace dependency cycle: %s", strings.Join(path, " -> "))
+		}
+
+		visiting[w.Name] = true
+
+		deps := w.Package.DependencyNames()
+		slices.Sort(deps)
+
+		for _, dep := range deps {
+			if d, ok := byName[dep]; ok {
+				if err := visit(d, path); err != nil {
+					return err
+				}
+			}
+		}
+
+		visited[w.Name] = true
+		sorted = append(sorted, w)
+
+		return nil
+	}
+
+	for _, w := range workspaces {
+		if err := visit(w, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// WorkspaceDependencies returns, for each workspace, the names of the other
+// workspaces it depends on, directly or through other workspaces.
+func WorkspaceDependencies(workspaces []Workspace) map[string][]string {
+	byName := workspacesByName(workspaces)
+	deps := make(map[string][]string, len(workspaces))
+
+	for _, w := range workspaces {
+		seen := map[string]bool{w.Name: true}
+		queue := []Workspace{w}
+
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+
+			for _, dep := range next.Package.DependencyNames() {
+				if d, ok := byName[dep]; ok && !seen[dep] {
+					seen[dep] = true
+					deps[w.Name] = append(deps[w.Name], dep)
+					queue = append(queue, d)
+				}
+			}
+		}
+	}
+
+	return deps
+}
+
+func workspacesByName(workspaces []Workspace) map[string]Workspace {
+	byName := make(map[string]Workspace, len(workspaces))
+
+	for _, w := range workspaces {
+		byName[w.Name] = w
+	}
+
+	return byName
+}